@@ -0,0 +1,28 @@
+package hcl2yaml
+
+import (
+	"github.com/agext/levenshtein"
+	"sort"
+)
+
+// nameSuggestion returns the name closest to the given one in terms of the edit distance,
+// or an empty string if none is close enough.
+//
+// The threshold is the same as the one used by HCL for its "Did you mean" suggestions.
+func nameSuggestion(given string, names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	var suggestion string
+
+	best := 3
+
+	for _, name := range sorted {
+		if dist := levenshtein.Distance(given, name, nil); dist < best {
+			suggestion = name
+			best = dist
+		}
+	}
+
+	return suggestion
+}
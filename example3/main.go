@@ -161,6 +161,9 @@ intval: 1
 				FooFirstLabel string `hcl:"fooFirstLabel,attr"`
 				Baz           string `hcl:"baz,attr"`
 			} `hcl:"foo,block"`
+			Hoge struct {
+				Fuga string `hcl:"fuga,attr"`
+			} `hcl:"hoge,block"`
 		}
 
 		var result Result
@@ -7,87 +7,161 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// MappingExpression is the expression of a YAML mapping, which evaluates to an object.
+//
+// It is immutable once built, so that it can be evaluated concurrently with different EvalContexts.
 type MappingExpression struct {
-	f *yamlBody
-	Node     *yaml.Node
+	f    *yamlBody
+	Node *yaml.Node
+
+	// ancestors are the YAML nodes enclosing Node, used to detect cyclic aliases.
+	ancestors []*yaml.Node
+
+	// keys are the key nodes of the mapping in the source order, and exprs are the expressions of the values.
+	// They are built once by newMappingExpression, so that evaluating the expression doesn't parse the values again.
+	keys  []*yaml.Node
+	exprs map[string]hcl.Expression
+
+	// diags are the diagnostics for the values that failed to parse, which are reported by Value too.
+	diags hcl.Diagnostics
+}
+
+// newMappingExpression builds the expression of the YAML mapping along with the expressions of all the nested values.
+// The expression is returned even when some values failed to parse, so that the rest of them can still be inspected.
+func newMappingExpression(f *yamlBody, node *yaml.Node, ancestors []*yaml.Node) (*MappingExpression, hcl.Diagnostics) {
+	e := &MappingExpression{f: f, Node: node, ancestors: ancestors}
+
+	e.keys, e.exprs, e.diags = e.parseExprs(node)
+
+	return e, sharedDiags(e.diags)
 }
 
 func (e MappingExpression) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
-	exprs, diags := e.parseExprs(e.Node)
-	if diags.HasErrors() {
-		panic(diags)
+	if e.diags.HasErrors() {
+		return cty.DynamicVal, sharedDiags(e.diags)
 	}
 
 	vals := map[string]cty.Value{}
 
-	for k, expr := range exprs {
+	// The values are evaluated in the source order, so that the first error is always the same.
+	for _, k := range e.keys {
+		expr, ok := e.exprs[k.Value]
+		if !ok {
+			continue
+		}
+
 		val, diags := expr.Value(ctx)
 		if diags.HasErrors() {
 			return cty.DynamicVal, diags
 		}
 
-		vals[k] = val
+		vals[k.Value] = val
 	}
 
-	return cty.MapVal(vals), nil
+	// Like HCL's JSON syntax, a mapping produces an object rather than a map so that
+	// values of different types can coexist. It is converted to a map when the
+	// target type demands it.
+	return cty.ObjectVal(vals), nil
 }
 
-func (e MappingExpression) parseExprs(v *yaml.Node) (map[string]hcl.Expression, hcl.Diagnostics) {
-	m := mappingKVs(v)
+// parseExprs returns the expressions of the mapping values keyed by the keys, along with the keys in the source order.
+func (e MappingExpression) parseExprs(v *yaml.Node) ([]*yaml.Node, map[string]hcl.Expression, hcl.Diagnostics) {
+	entries, diags := mappingEntries(e.f.src, v, e.ancestors)
+
+	parents := appendNode(e.ancestors, v)
+
+	var keys []*yaml.Node
 
 	exprs := map[string]hcl.Expression{}
 
-	for k, v := range m {
+	for _, entry := range entries {
+		if entry.key.Kind != yaml.ScalarNode {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Unexpected key kind. Expected ScalarNode(8), got %v", entry.key.Kind),
+				Subject:  e.f.src.nodeRange(entry.key).Ptr(),
+				Context:  e.f.src.nodeRange(v).Ptr(),
+			})
+
+			continue
+		}
+
+		k, v := entry.key.Value, entry.value
+
+		keys = append(keys, entry.key)
+
 		switch v.Kind {
 		case yaml.MappingNode:
-			exprs[k] = &MappingExpression{f: e.f, Node: v}
+			expr, exprDiags := newMappingExpression(e.f, v, parents)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, entry.alias)...)
+
+			exprs[k] = expr
 		case yaml.ScalarNode:
-			expr, diags := e.f.ParseScalar(v)
-			if diags.HasErrors() {
-				return nil, diags
+			expr, exprDiags := e.f.ParseScalar(v)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, entry.alias)...)
+
+			if exprDiags.HasErrors() {
+				continue
 			}
 
 			exprs[k] = expr
 		case yaml.SequenceNode:
+			expr, exprDiags := newSequenceExpression(e.f, v, parents)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, entry.alias)...)
+
+			exprs[k] = expr
 		default:
-			panic(fmt.Errorf("unsupported yaml kind: %v: %v", v.Kind, v))
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("unsupported yaml kind: %v", v.Kind),
+				Detail:   fmt.Sprintf("The value for the key %q must be a YAML scalar, mapping, or sequence.", k),
+				Subject:  e.f.src.nodeRange(v).Ptr(),
+				Context:  e.Range().Ptr(),
+			})
 		}
 	}
 
-	return exprs, nil
+	return keys, exprs, diags
 }
 
+// Variables returns the variables referenced by the nested expressions.
+// Nested values that failed to parse are skipped, as their diagnostics are reported by Value.
 func (e MappingExpression) Variables() []hcl.Traversal {
-	exprs, diags := e.parseExprs(e.Node)
-	if diags.HasErrors() {
-		panic(diags)
-	}
-
 	var vars []hcl.Traversal
 
-	for _, expr := range exprs {
-		vars = append(vars, expr.Variables()...)
+	for _, k := range e.keys {
+		if expr, ok := e.exprs[k.Value]; ok {
+			vars = append(vars, expr.Variables()...)
+		}
 	}
 
 	return vars
 }
 
-func (e MappingExpression) Range() hcl.Range {
-	n := e.Node
-
-	lastElem := n.Content[len(n.Content)-1]
-
-	return hcl.Range{
-		Filename: e.f.fileName,
-		Start: hcl.Pos{
-			Column: n.Column,
-			Line:   n.Line,
-		},
-		End: hcl.Pos{
-			Column: n.Column + len(lastElem.Value),
-			Line:   n.Line,
-		},
+// ExprMap returns the expressions of the keys and the values in the source order, so that hcl.ExprMap
+// works on YAML mappings. Keys are static strings, which can also be interpreted as traversals or keywords.
+// It returns nil if any of the values failed to parse.
+func (e MappingExpression) ExprMap() []hcl.KeyValuePair {
+	if e.diags.HasErrors() {
+		return nil
 	}
+
+	pairs := make([]hcl.KeyValuePair, 0, len(e.keys))
+
+	for _, k := range e.keys {
+		key := hcl.StaticExpr(cty.StringVal(k.Value), e.f.src.nodeRange(k))
+
+		pairs = append(pairs, hcl.KeyValuePair{
+			Key:   newStringExpression(e.f.src, k, key),
+			Value: e.exprs[k.Value],
+		})
+	}
+
+	return pairs
+}
+
+func (e MappingExpression) Range() hcl.Range {
+	return e.f.src.nodeRange(e.Node)
 }
 
 func (e MappingExpression) StartRange() hcl.Range {
@@ -7,20 +7,42 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// SequenceExpression is the expression of a YAML sequence, which evaluates to a tuple.
+//
+// It is immutable once built, so that it can be evaluated concurrently with different EvalContexts.
 type SequenceExpression struct {
 	f    *yamlBody
 	Node *yaml.Node
+
+	// ancestors are the YAML nodes enclosing Node, used to detect cyclic aliases.
+	ancestors []*yaml.Node
+
+	// exprs are the expressions of the items, built once by newSequenceExpression
+	// so that evaluating the expression doesn't parse the items again.
+	exprs []hcl.Expression
+
+	// diags are the diagnostics for the items that failed to parse, which are reported by Value too.
+	diags hcl.Diagnostics
+}
+
+// newSequenceExpression builds the expression of the YAML sequence along with the expressions of all the nested items.
+// The expression is returned even when some items failed to parse, so that the rest of them can still be inspected.
+func newSequenceExpression(f *yamlBody, node *yaml.Node, ancestors []*yaml.Node) (*SequenceExpression, hcl.Diagnostics) {
+	e := &SequenceExpression{f: f, Node: node, ancestors: ancestors}
+
+	e.exprs, e.diags = e.parseExprs(node)
+
+	return e, sharedDiags(e.diags)
 }
 
 func (e SequenceExpression) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
-	exprs, diags := e.parseExprs(e.Node)
-	if diags.HasErrors() {
-		panic(diags)
+	if e.diags.HasErrors() {
+		return cty.DynamicVal, sharedDiags(e.diags)
 	}
 
 	vals := []cty.Value{}
 
-	for _, expr := range exprs {
+	for _, expr := range e.exprs {
 		val, diags := expr.Value(ctx)
 		if diags.HasErrors() {
 			return cty.DynamicVal, diags
@@ -29,64 +51,89 @@ func (e SequenceExpression) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnost
 		vals = append(vals, val)
 	}
 
-	return cty.ListVal(vals), nil
+	// Like HCL's JSON syntax, a sequence produces a tuple rather than a list so that
+	// items of different types can coexist. It is converted to a list when the
+	// target type demands it.
+	return cty.TupleVal(vals), nil
 }
 
 func (e SequenceExpression) parseExprs(v *yaml.Node) ([]hcl.Expression, hcl.Diagnostics) {
 	var exprs []hcl.Expression
 
-	for _, v := range v.Content {
+	var diags hcl.Diagnostics
+
+	parents := appendNode(e.ancestors, v)
+
+	for _, item := range v.Content {
+		v, resolveDiags := resolveNode(e.f.src, item, parents)
+		diags = append(diags, resolveDiags...)
+
+		if resolveDiags.HasErrors() {
+			continue
+		}
+
 		switch v.Kind {
 		case yaml.MappingNode:
-			exprs = append(exprs, &MappingExpression{f: e.f, Node: v})
+			expr, exprDiags := newMappingExpression(e.f, v, parents)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, item)...)
+
+			exprs = append(exprs, expr)
 		case yaml.ScalarNode:
-			expr, diags := e.f.ParseScalar(v)
-			if diags.HasErrors() {
-				return nil, diags
+			expr, exprDiags := e.f.ParseScalar(v)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, item)...)
+
+			if exprDiags.HasErrors() {
+				continue
 			}
 
 			exprs = append(exprs, expr)
 		case yaml.SequenceNode:
-			exprs = append(exprs, &SequenceExpression{f: e.f, Node: v})
+			expr, exprDiags := newSequenceExpression(e.f, v, parents)
+			diags = append(diags, withAliasContext(e.f.src, exprDiags, item)...)
+
+			exprs = append(exprs, expr)
 		default:
-			panic(fmt.Errorf("unsupported yaml kind: %v: %v", v.Kind, v))
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("unsupported yaml kind: %v", v.Kind),
+				Detail:   "Each item of the sequence must be a YAML scalar, mapping, or sequence.",
+				Subject:  e.f.src.nodeRange(v).Ptr(),
+				Context:  e.Range().Ptr(),
+			})
 		}
 	}
 
-	return exprs, nil
+	return exprs, diags
 }
 
+// Variables returns the variables referenced by the nested expressions.
+// Nested values that failed to parse are skipped, as their diagnostics are reported by Value.
 func (e SequenceExpression) Variables() []hcl.Traversal {
-	exprs, diags := e.parseExprs(e.Node)
-	if diags.HasErrors() {
-		panic(diags)
-	}
-
 	var vars []hcl.Traversal
 
-	for _, expr := range exprs {
+	for _, expr := range e.exprs {
 		vars = append(vars, expr.Variables()...)
 	}
 
 	return vars
 }
 
-func (e SequenceExpression) Range() hcl.Range {
-	n := e.Node
-
-	lastElem := n.Content[len(n.Content)-1]
-
-	return hcl.Range{
-		Filename: e.f.fileName,
-		Start: hcl.Pos{
-			Column: n.Column,
-			Line:   n.Line,
-		},
-		End: hcl.Pos{
-			Column: n.Column + len(lastElem.Value),
-			Line:   n.Line,
-		},
+// ExprList returns the expressions of the items, so that hcl.ExprList works on YAML sequences.
+// It returns nil if any of the items failed to parse.
+func (e SequenceExpression) ExprList() []hcl.Expression {
+	if e.diags.HasErrors() {
+		return nil
 	}
+
+	exprs := make([]hcl.Expression, len(e.exprs))
+
+	copy(exprs, e.exprs)
+
+	return exprs
+}
+
+func (e SequenceExpression) Range() hcl.Range {
+	return e.f.src.nodeRange(e.Node)
 }
 
 func (e SequenceExpression) StartRange() hcl.Range {
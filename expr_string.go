@@ -0,0 +1,54 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"gopkg.in/yaml.v3"
+)
+
+// stringExpression is the expression of a YAML string, like a template parsed from a string scalar
+// or the key of a mapping.
+//
+// Like strings in HCL's JSON syntax, it can also be interpreted as a static traversal like `aws_instance.web`,
+// so that hcl.AbsTraversalForExpr and hcl.ExprAsKeyword work on YAML strings.
+type stringExpression struct {
+	hcl.Expression
+
+	src  *source
+	node *yaml.Node
+}
+
+func newStringExpression(src *source, node *yaml.Node, expr hcl.Expression) *stringExpression {
+	return &stringExpression{Expression: expr, src: src, node: node}
+}
+
+// AsTraversal returns the string parsed as an absolute traversal, or nil if it isn't one.
+func (e *stringExpression) AsTraversal() hcl.Traversal {
+	offsets := e.src.scalarOffsets(e.node)
+
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(e.node.Value), e.src.fileName, offsets.start)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	offsets.remap(traversal)
+
+	return traversal
+}
+
+// Range returns the range of the YAML scalar. It differs from the range of the template, which is computed from
+// the value, when the scalar spans multiple lines or contains escape sequences.
+func (e *stringExpression) Range() hcl.Range {
+	return e.src.nodeRange(e.node)
+}
+
+func (e *stringExpression) StartRange() hcl.Range {
+	return e.Range()
+}
+
+// UnwrapExpression returns the expression the string evaluates with.
+func (e *stringExpression) UnwrapExpression() hcl.Expression {
+	return e.Expression
+}
+
+var _ hcl.Expression = &stringExpression{}
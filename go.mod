@@ -3,6 +3,7 @@ module github.com/mumoshu/hcl2-yaml
 go 1.13
 
 require (
+	github.com/agext/levenshtein v1.2.2
 	github.com/google/go-cmp v0.3.1
 	github.com/hashicorp/hcl/v2 v2.4.0
 	github.com/hashicorp/terraform v0.12.25
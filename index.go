@@ -0,0 +1,62 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// mappingIndex is the entries of a YAML mapping in order, indexed by their keys.
+//
+// It is built once per body, so that decoding a body, possibly many times with different schemas,
+// doesn't walk the mapping, resolve aliases and expand merge keys again.
+type mappingIndex struct {
+	// node is the YAML mapping.
+	node *yaml.Node
+
+	entries []mappingEntry
+
+	// byKey contains the positions in entries of the entries with scalar keys.
+	byKey map[string]int
+
+	// diags are the diagnostics for the mapping itself, like duplicate keys and invalid merge keys.
+	diags hcl.Diagnostics
+}
+
+// newMappingIndex indexes the YAML mapping, or returns nil if the node is not a mapping.
+// A document node is indexed as its root node.
+func newMappingIndex(src *source, node *yaml.Node, ancestors []*yaml.Node) *mappingIndex {
+	if node.Kind == yaml.DocumentNode {
+		node = documentContent(node)
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	entries, diags := mappingEntries(src, node, ancestors)
+
+	idx := &mappingIndex{
+		node:    node,
+		entries: entries,
+		byKey:   make(map[string]int, len(entries)),
+		diags:   diags,
+	}
+
+	for i, e := range entries {
+		if e.key.Kind == yaml.ScalarNode {
+			idx.byKey[e.key.Value] = i
+		}
+	}
+
+	return idx
+}
+
+// get returns the entry of the scalar key.
+func (idx *mappingIndex) get(key string) (mappingEntry, bool) {
+	i, ok := idx.byKey[key]
+	if !ok {
+		return mappingEntry{}, false
+	}
+
+	return idx.entries[i], true
+}
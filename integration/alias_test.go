@@ -0,0 +1,259 @@
+package integration
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+defaults: &defaults
+  image: nginx
+  replicas: 1
+
+ports: &ports [80, 443]
+
+name: &name web
+
+service:
+- <<: *defaults
+  name: *name
+  ports: *ports
+- <<: [{replicas: 3, image: ignored}, *defaults]
+  name: api
+  ports: []
+- &svc
+  <<: *defaults
+  image: custom
+  name: db
+  ports:
+  - *name
+`)
+
+	type Service struct {
+		Name     string   `hcl:"name,attr"`
+		Image    string   `hcl:"image,attr"`
+		Replicas int      `hcl:"replicas,attr"`
+		Ports    []string `hcl:"ports,attr"`
+	}
+
+	type Result struct {
+		Defaults map[string]string `hcl:"defaults,attr"`
+		Ports    []int             `hcl:"ports,attr"`
+		Name     string            `hcl:"name,attr"`
+		Services []Service         `hcl:"service,block"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	var got Result
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(gohcl.DecodeBody(file.Body, nil, &got))
+
+	want := Result{
+		Defaults: map[string]string{"image": "nginx", "replicas": "1"},
+		Ports:    []int{80, 443},
+		Name:     "web",
+		Services: []Service{
+			{Name: "web", Image: "nginx", Replicas: 1, Ports: []string{"80", "443"}},
+			{Name: "api", Image: "ignored", Replicas: 3, Ports: []string{}},
+			{Name: "db", Image: "custom", Replicas: 1, Ports: []string{"web"}},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestAliases_Cycle(t *testing.T) {
+	fileName := "example.yaml"
+
+	testcases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "mapping",
+			yaml: `
+map1: &a
+  foo:
+    bar: *a
+`,
+		},
+		{
+			name: "sequence",
+			yaml: `
+map1: &a
+- 1
+- [*a]
+`,
+		},
+		{
+			name: "merge key",
+			yaml: `
+map1: &a
+  foo:
+    <<: *a
+`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), fileName)
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			var dynamic struct {
+				Map1 hcl.Expression `hcl:"map1,attr"`
+			}
+
+			// Nested values are parsed along with the body, so the cycle is reported on decoding the body.
+			diags = gohcl.DecodeBody(file.Body, nil, &dynamic)
+			if !diags.HasErrors() {
+				t.Fatalf("expected an error, got none")
+			}
+
+			if diags[0].Summary != "Cyclic alias" {
+				t.Errorf("unexpected diagnostic: %v", diags[0])
+			}
+
+		})
+	}
+}
+
+func TestAliases_DiagnosticPointsAtAliasAndAnchor(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+bad: &bad !!exp 1 +
+map1:
+  foo: *bad
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	var dynamic struct {
+		Map1   hcl.Expression `hcl:"map1,attr"`
+		Remain hcl.Body       `hcl:",remain"`
+	}
+
+	diags = gohcl.DecodeBody(file.Body, nil, &dynamic)
+	if !diags.HasErrors() {
+		t.Fatalf("expected an error, got none")
+	}
+
+	if diags[0].Subject.Start.Line != 2 {
+		t.Errorf("expected the diagnostic to point at the anchor at line 2, got %v", diags[0].Subject)
+	}
+
+	if !strings.Contains(diags[0].Detail, "example.yaml:4,8-12") {
+		t.Errorf("expected the diagnostic to mention the alias, got %q", diags[0].Detail)
+	}
+}
+
+func TestAliases_InvalidMergeKeyValue(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+ports: &ports [80, 443]
+service:
+  <<: *ports
+  name: web
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	_, diags = file.Body.JustAttributes()
+	if !diags.HasErrors() {
+		t.Fatalf("expected an error, got none")
+	}
+
+	if diags[0].Summary != "Invalid merge key value" {
+		t.Errorf("unexpected diagnostic: %v", diags[0])
+	}
+
+	if !strings.HasSuffix(diags[0].Detail, "but got a sequence.") {
+		t.Errorf("expected the diagnostic to name the kind of the value, got %q", diags[0].Detail)
+	}
+}
+
+// aliasBomb returns a YAML document with the given levels of nested aliases, each of which refers to
+// the previous level 10 times, similarly to the "billion laughs" attack on XML.
+func aliasBomb(levels int, mergeKeys bool) string {
+	var s strings.Builder
+
+	s.WriteString("l0: &l0\n  lol: lol\n")
+
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&s, "l%d: &l%d\n", i, i)
+
+		for j := 0; j < 10; j++ {
+			if mergeKeys {
+				fmt.Fprintf(&s, "  k%d:\n    <<: *l%d\n", j, i-1)
+			} else {
+				fmt.Fprintf(&s, "  k%d: *l%d\n", j, i-1)
+			}
+		}
+	}
+
+	return s.String()
+}
+
+func TestAliases_Bomb(t *testing.T) {
+	fileName := "example.yaml"
+
+	for _, mergeKeys := range []bool{false, true} {
+		t.Run(fmt.Sprintf("merge keys %v", mergeKeys), func(t *testing.T) {
+			_, diags := hcl2yaml.Parse([]byte(aliasBomb(9, mergeKeys)), fileName)
+			if !diags.HasErrors() {
+				t.Fatalf("expected an error, got none")
+			}
+
+			if diags[0].Summary != "Too many alias expansions" {
+				t.Errorf("unexpected diagnostic: %v", diags[0])
+			}
+
+			if diags[0].Subject == nil || diags[0].Subject.Start.Line < 3 {
+				t.Errorf("expected the diagnostic to point at one of the aliases, got %v", diags[0].Subject)
+			}
+
+			_, diags = hcl2yaml.ParseMulti([]byte("foo: bar\n---\n"+aliasBomb(9, mergeKeys)), fileName)
+			if !diags.HasErrors() {
+				t.Fatalf("expected an error, got none")
+			}
+		})
+	}
+
+	t.Run("moderate aliases", func(t *testing.T) {
+		file, diags := hcl2yaml.Parse([]byte(aliasBomb(3, true)), fileName)
+
+		FailOnError(t, map[string]*hcl.File{})(diags)
+
+		attrs, diags := file.Body.JustAttributes()
+
+		FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+		v, diags := attrs["l3"].Expr.Value(nil)
+
+		FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+		if got := v.GetAttr("k9").GetAttr("k9").GetAttr("k9").GetAttr("lol").AsString(); got != "lol" {
+			t.Errorf("unexpected value: %q", got)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"reflect"
+	"testing"
+)
+
+// generateYAML generates a YAML document containing n attributes, each of which is a nested mapping
+// containing templates and sequences, like large configs do.
+func generateYAML(n int) []byte {
+	var buf bytes.Buffer
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "attr%d:\n", i)
+		fmt.Fprintf(&buf, "  name: \"name-${var.suffix}-%d\"\n", i)
+		fmt.Fprintf(&buf, "  count: %d\n", i)
+		fmt.Fprintf(&buf, "  enabled: true\n")
+		fmt.Fprintf(&buf, "  tags:\n")
+		fmt.Fprintf(&buf, "  - \"a-${var.suffix}\"\n")
+		fmt.Fprintf(&buf, "  - b\n")
+		fmt.Fprintf(&buf, "  nested:\n")
+		fmt.Fprintf(&buf, "    expr: !!exp var.suffix\n")
+		fmt.Fprintf(&buf, "    list: [1, 2, 3]\n")
+	}
+
+	return buf.Bytes()
+}
+
+func benchmarkEvalContext(i int) *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"suffix": cty.StringVal(fmt.Sprintf("s%d", i)),
+			}),
+		},
+	}
+}
+
+// BenchmarkEvaluate measures evaluating a parsed YAML file repeatedly with different EvalContexts.
+func BenchmarkEvaluate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("attrs=%d", n), func(b *testing.B) {
+			file, diags := hcl2yaml.Parse(generateYAML(n), "example.yaml")
+			if diags.HasErrors() {
+				b.Fatal(diags.Error())
+			}
+
+			attrs, diags := file.Body.JustAttributes()
+			if diags.HasErrors() {
+				b.Fatal(diags.Error())
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				ctx := benchmarkEvalContext(i)
+
+				for _, attr := range attrs {
+					if len(attr.Expr.Variables()) == 0 {
+						b.Fatal("expected variables")
+					}
+
+					if _, diags := attr.Expr.Value(ctx); diags.HasErrors() {
+						b.Fatal(diags.Error())
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseAndEvaluate measures parsing a YAML file and evaluating all of its attributes once.
+func BenchmarkParseAndEvaluate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("attrs=%d", n), func(b *testing.B) {
+			src := generateYAML(n)
+			ctx := benchmarkEvalContext(0)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				file, diags := hcl2yaml.Parse(src, "example.yaml")
+				if diags.HasErrors() {
+					b.Fatal(diags.Error())
+				}
+
+				attrs, diags := file.Body.JustAttributes()
+				if diags.HasErrors() {
+					b.Fatal(diags.Error())
+				}
+
+				for _, attr := range attrs {
+					if _, diags := attr.Expr.Value(ctx); diags.HasErrors() {
+						b.Fatal(diags.Error())
+					}
+				}
+			}
+		})
+	}
+}
+
+// generateBlocksYAML generates a YAML document containing n labeled blocks of the type "service".
+func generateBlocksYAML(n int) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "services:\n")
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "- name: service%d\n", i)
+		fmt.Fprintf(&buf, "  image: \"image-${var.suffix}\"\n")
+		fmt.Fprintf(&buf, "  replicas: %d\n", i)
+	}
+
+	return buf.Bytes()
+}
+
+type benchmarkService struct {
+	Name     string `hcl:"name,label"`
+	Image    string `hcl:"image"`
+	Replicas int    `hcl:"replicas"`
+}
+
+type benchmarkServices struct {
+	Services []benchmarkService `hcl:"service,block"`
+}
+
+// BenchmarkDecodeBody measures decoding a parsed YAML file containing 10k blocks with gohcl.
+func BenchmarkDecodeBody(b *testing.B) {
+	file, diags := hcl2yaml.Parse(generateBlocksYAML(10000), "example.yaml")
+	if diags.HasErrors() {
+		b.Fatal(diags.Error())
+	}
+
+	ctx := benchmarkEvalContext(0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var result benchmarkServices
+
+		if diags := gohcl.DecodeBody(file.Body, ctx, &result); diags.HasErrors() {
+			b.Fatal(diags.Error())
+		}
+
+		if len(result.Services) != 10000 {
+			b.Fatalf("unexpected number of blocks: %d", len(result.Services))
+		}
+	}
+}
+
+// BenchmarkDecodeBodyIntoMap measures decoding a parsed YAML file containing 10k blocks with DecodeBodyIntoMap.
+func BenchmarkDecodeBodyIntoMap(b *testing.B) {
+	file, diags := hcl2yaml.Parse(generateBlocksYAML(10000), "example.yaml")
+	if diags.HasErrors() {
+		b.Fatal(diags.Error())
+	}
+
+	ctx := benchmarkEvalContext(0)
+
+	schema := hcl2yaml.MapSchema{
+		Blocks: map[string]hcl2yaml.Block{
+			"service": {
+				LabelNames: []string{"name"},
+				Attributes: map[string]hcl2yaml.Attribute{
+					"image":    {Kind: reflect.String},
+					"replicas": {Kind: reflect.Int},
+				},
+			},
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m := map[string]interface{}{}
+
+		if diags := hcl2yaml.DecodeBodyIntoMap(ctx, file.Body, schema, m); diags.HasErrors() {
+			b.Fatal(diags.Error())
+		}
+
+		if len(m["service"].([]interface{})) != 10000 {
+			b.Fatalf("unexpected number of blocks: %d", len(m["service"].([]interface{})))
+		}
+	}
+}
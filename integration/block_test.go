@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGohclIntegration_OptionalBlocks(t *testing.T) {
+	type Foo struct {
+		Baz string `hcl:"baz,attr"`
+	}
+
+	type Config struct {
+		Hello string `hcl:"hello,attr"`
+		Foo   *Foo   `hcl:"foo,block"`
+		Foos  []Foo  `hcl:"bar,block"`
+	}
+
+	file, diags := hcl2yaml.Parse([]byte("hello: world\n"), "example.yaml")
+
+	files := map[string]*hcl.File{"example.yaml": file}
+
+	FailOnError(t, files)(diags)
+
+	var config Config
+
+	FailOnError(t, files)(gohcl.DecodeBody(file.Body, nil, &config))
+
+	if config.Foo != nil {
+		t.Errorf("expected no foo block, got %v", config.Foo)
+	}
+
+	if len(config.Foos) != 0 {
+		t.Errorf("expected no bar blocks, got %v", config.Foos)
+	}
+
+	var required struct {
+		Hello string `hcl:"hello,attr"`
+		Foo   Foo    `hcl:"foo,block"`
+	}
+
+	diags = gohcl.DecodeBody(file.Body, nil, &required)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error for the missing required block")
+	}
+
+	if diags[0].Summary != "Missing foo block" {
+		t.Errorf("unexpected summary: %s", diags[0].Summary)
+	}
+}
+
+func TestDecodeBodyIntoMap_BlockCount(t *testing.T) {
+	testcases := []struct {
+		name    string
+		yaml    string
+		block   hcl2yaml.Block
+		summary string
+	}{
+		{
+			name:  "optional",
+			yaml:  "{}",
+			block: hcl2yaml.Block{},
+		},
+		{
+			name:    "required",
+			yaml:    "{}",
+			block:   hcl2yaml.Block{Required: true},
+			summary: "Missing foo block",
+		},
+		{
+			name:    "min items",
+			yaml:    "foos:\n- baz: a\n",
+			block:   hcl2yaml.Block{MinItems: 2},
+			summary: "Insufficient foo blocks",
+		},
+		{
+			name:  "max items",
+			yaml:  "foos:\n- baz: a\n- baz: b\n",
+			block: hcl2yaml.Block{MaxItems: 2},
+		},
+		{
+			name:    "too many",
+			yaml:    "foos:\n- baz: a\n- baz: b\n",
+			block:   hcl2yaml.Block{MaxItems: 1},
+			summary: "Too many foo blocks",
+		},
+		{
+			name:  "singleton",
+			yaml:  "foo:\n  baz: a\n",
+			block: hcl2yaml.Block{Singleton: true},
+		},
+		{
+			name:    "too many singletons",
+			yaml:    "foos:\n- baz: a\n- baz: b\n",
+			block:   hcl2yaml.Block{Singleton: true},
+			summary: "Too many foo blocks found",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			tc.block.Attributes = map[string]hcl2yaml.Attribute{
+				"baz": {Kind: reflect.String},
+			}
+
+			schema := hcl2yaml.MapSchema{
+				Blocks: map[string]hcl2yaml.Block{"foo": tc.block},
+			}
+
+			diags = hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, map[string]interface{}{})
+
+			if tc.summary == "" {
+				FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+				return
+			}
+
+			if !diags.HasErrors() {
+				t.Fatalf("expected an error %q", tc.summary)
+			}
+
+			if !strings.HasPrefix(diags[0].Summary, tc.summary) {
+				t.Errorf("unexpected summary: expected %q, got %q", tc.summary, diags[0].Summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package integration
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const concurrency = 16
+
+func concurrencyEvalContext(i int) *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"env": cty.StringVal(fmt.Sprintf("env%d", i)),
+			}),
+		},
+	}
+}
+
+// runConcurrently runs f from many goroutines at once, to let the race detector catch shared state being modified.
+func runConcurrently(t *testing.T, f func(i int) error) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, concurrency)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = f(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("goroutine %d: %v", i, err)
+		}
+	}
+}
+
+func TestConcurrentDecodeBody(t *testing.T) {
+	yamlSource := []byte(`
+defaults: &defaults
+  image: "nginx-${var.env}"
+
+name: "app-${var.env}"
+tags:
+- "${var.env}"
+- static
+labels:
+  env: !!exp var.env
+services:
+- <<: *defaults
+  name: web
+- <<: *defaults
+  name: api
+  image: "api-${var.env}"
+`)
+
+	type Service struct {
+		Name  string `hcl:"name,label"`
+		Image string `hcl:"image"`
+	}
+
+	type Result struct {
+		Name     string            `hcl:"name"`
+		Tags     []string          `hcl:"tags"`
+		Labels   map[string]string `hcl:"labels"`
+		Services []Service         `hcl:"service,block"`
+		Remain   hcl.Body          `hcl:",remain"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	runConcurrently(t, func(i int) error {
+		env := fmt.Sprintf("env%d", i)
+
+		for j := 0; j < 10; j++ {
+			var got Result
+
+			if diags := gohcl.DecodeBody(file.Body, concurrencyEvalContext(i), &got); diags.HasErrors() {
+				return diags
+			}
+
+			want := Result{
+				Name:   "app-" + env,
+				Tags:   []string{env, "static"},
+				Labels: map[string]string{"env": env},
+				Services: []Service{
+					{Name: "web", Image: "nginx-" + env},
+					{Name: "api", Image: "api-" + env},
+				},
+			}
+
+			if diff := cmp.Diff(want, got, cmp.FilterPath(func(p cmp.Path) bool { return p.Last().String() == ".Remain" }, cmp.Ignore())); diff != "" {
+				return fmt.Errorf("unexpected diff:\n%s", diff)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestConcurrentDecodeBodyIntoMap(t *testing.T) {
+	yamlSource := []byte(`
+hello: "hello-${var.env}"
+foos:
+- name: foo1
+  baz: "baz-${var.env}"
+- name: foo2
+  baz: BAZ2
+`)
+
+	schema := hcl2yaml.MapSchema{
+		Attributes: map[string]hcl2yaml.Attribute{
+			"hello": {Kind: reflect.String},
+		},
+		Blocks: map[string]hcl2yaml.Block{
+			"foo": {
+				LabelNames: []string{"name"},
+				Attributes: map[string]hcl2yaml.Attribute{
+					"baz": {Kind: reflect.String},
+				},
+			},
+		},
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	runConcurrently(t, func(i int) error {
+		env := fmt.Sprintf("env%d", i)
+
+		for j := 0; j < 10; j++ {
+			got := map[string]interface{}{}
+
+			if diags := hcl2yaml.DecodeBodyIntoMap(concurrencyEvalContext(i), file.Body, schema, got); diags.HasErrors() {
+				return diags
+			}
+
+			want := map[string]interface{}{
+				"hello": "hello-" + env,
+				"foo": []interface{}{
+					map[string]interface{}{"name": "foo1", "baz": "baz-" + env},
+					map[string]interface{}{"name": "foo2", "baz": "BAZ2"},
+				},
+			}
+
+			if diff := cmp.Diff(want, got); diff != "" {
+				return fmt.Errorf("unexpected diff:\n%s", diff)
+			}
+		}
+
+		return nil
+	})
+}
+
+// TestConcurrentDiagnostics verifies that callers appending to the diagnostics retained by the parsed tree
+// never modify them for other callers.
+func TestConcurrentDiagnostics(t *testing.T) {
+	yamlSource := []byte(`
+invalid: &invalid
+  expr1: !!exp "1 +"
+  expr2: !!exp "2 +"
+  expr3: !!exp "3 +"
+a: *invalid
+b: *invalid
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+	if !diags.HasErrors() {
+		t.Fatal("expected errors")
+	}
+
+	runConcurrently(t, func(i int) error {
+		for j := 0; j < 10; j++ {
+			for _, name := range []string{"a", "b"} {
+				_, diags := attrs[name].Expr.Value(concurrencyEvalContext(i))
+				if !diags.HasErrors() {
+					return fmt.Errorf("expected errors for %s", name)
+				}
+
+				diags = append(diags, &hcl.Diagnostic{Severity: hcl.DiagWarning, Summary: fmt.Sprintf("appended by %d", i)})
+
+				if last := diags[len(diags)-1]; last.Summary != fmt.Sprintf("appended by %d", i) {
+					return fmt.Errorf("unexpected diagnostic: %s", last.Summary)
+				}
+
+				for _, d := range diags {
+					if strings.Count(d.Detail, "referenced by the alias") > 1 {
+						return fmt.Errorf("alias context added more than once: %s", d.Detail)
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestConcurrentNavigation(t *testing.T) {
+	yamlSource := []byte(`
+service:
+  name: web
+  image: nginx
+`)
+
+	type Service struct {
+		Name  string `hcl:"name,label"`
+		Image string `hcl:"image"`
+	}
+
+	type Result struct {
+		Services []Service `hcl:"service,block"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	nav := file.Nav.(interface{ ContextString(offset int) string })
+
+	offset := strings.Index(string(yamlSource), "nginx")
+
+	// The navigation is built by Parse, so decoding the file concurrently never changes the answer.
+	want := nav.ContextString(offset)
+	if want != "service" {
+		t.Fatalf("unexpected context string: %q", want)
+	}
+
+	runConcurrently(t, func(i int) error {
+		for j := 0; j < 10; j++ {
+			var got Result
+
+			if diags := gohcl.DecodeBody(file.Body, concurrencyEvalContext(i), &got); diags.HasErrors() {
+				return diags
+			}
+
+			if s := nav.ContextString(offset); s != want {
+				return fmt.Errorf("unexpected context string: %q", s)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	type Service struct {
+		Name string `hcl:"name,attr"`
+	}
+
+	type Config struct {
+		Hello    string            `hcl:"hello,attr"`
+		Map1     map[string]string `hcl:"map1,optional"`
+		Services []Service         `hcl:"service,block"`
+	}
+
+	testcases := []struct {
+		name   string
+		yaml   string
+		detail string
+		line   int
+	}{
+		{
+			name: "attribute",
+			yaml: `hello: a
+hello: b
+`,
+			detail: `The key "hello" was already defined at example.yaml:1,1-6. Each key may be defined only once in a mapping.`,
+			line:   2,
+		},
+		{
+			name: "block",
+			yaml: `hello: a
+service:
+  name: a
+service:
+  name: b
+`,
+			detail: `The key "service" was already defined at example.yaml:2,1-8. Each key may be defined only once in a mapping.`,
+			line:   4,
+		},
+		{
+			name: "block body",
+			yaml: `hello: a
+service:
+  name: a
+  name: b
+`,
+			detail: `The key "name" was already defined at example.yaml:3,3-7. Each key may be defined only once in a mapping.`,
+			line:   4,
+		},
+		{
+			name: "nested mapping",
+			yaml: `hello: a
+map1:
+  foo: a
+  foo: b
+`,
+			detail: `The key "foo" was already defined at example.yaml:3,3-6. Each key may be defined only once in a mapping.`,
+			line:   4,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			var config Config
+
+			diags = gohcl.DecodeBody(file.Body, nil, &config)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error")
+			}
+
+			d := diags[0]
+
+			if d.Summary != "Duplicate key" || d.Detail != tc.detail {
+				t.Errorf("unexpected diagnostic: %s: %s", d.Summary, d.Detail)
+			}
+
+			if d.Subject == nil || d.Subject.Start.Line != tc.line {
+				t.Errorf("unexpected subject: %v", d.Subject)
+			}
+
+			if d.Context == nil || d.Context.Start.Line >= tc.line || d.Context.End != d.Subject.End {
+				t.Errorf("expected the context to span from the original key, got %v", d.Context)
+			}
+		})
+	}
+}
+
+func TestDuplicateKeys_MergeKeysAreNotDuplicates(t *testing.T) {
+	yamlSource := []byte(`base: &base
+  foo: a
+map1:
+  <<: *base
+  foo: b
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+	v, diags := attrs["map1"].Expr.Value(nil)
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+	if got := v.GetAttr("foo").AsString(); got != "b" {
+		t.Errorf("unexpected foo: %s", got)
+	}
+}
@@ -69,10 +69,15 @@ intval: 1
 		Baz           string `hcl:"baz,attr"`
 	}
 
+	type Hoge struct {
+		Fuga string `hcl:"fuga,attr"`
+	}
+
 	type Result struct {
 		Hello  string `hcl:"hello,attr"`
 		Intval int    `hcl:"intval,attr"`
 		Foos   []Foo  `hcl:"foo,block"`
+		Hoge   Hoge   `hcl:"hoge,block"`
 	}
 
 	var result Result
@@ -98,6 +103,9 @@ intval: 1
 				Baz:           "BAZ",
 			},
 		},
+		Hoge: Hoge{
+			Fuga: "FUGA",
+		},
 	}
 
 	if diff := cmp.Diff(want, result); diff != "" {
@@ -216,6 +224,513 @@ int1: !!exp 1 + 2
 	fmt.Fprintf(os.Stdout, "#3: %v\n", got2)
 }
 
+func TestGohclIntegration_Remain(t *testing.T) {
+	failOnError := FailOnError(t, map[string]*hcl.File{})
+
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+hello: "x${var.one}y"
+
+intval: 1
+`)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.MapVal(map[string]cty.Value{
+				"one": cty.StringVal("ONE"),
+			}),
+		},
+	}
+
+	type Result struct {
+		Hello  string   `hcl:"hello,attr"`
+		Remain hcl.Body `hcl:",remain"`
+	}
+
+	var result Result
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	failOnError(diags)
+
+	f := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	f(gohcl.DecodeBody(file.Body, ctx, &result))
+
+	if result.Hello != "xONEy" {
+		t.Errorf("unexpected hello: %q", result.Hello)
+	}
+
+	type Remain struct {
+		Hello  *string `hcl:"hello,attr"`
+		Intval int     `hcl:"intval,attr"`
+	}
+
+	var remain Remain
+
+	f(gohcl.DecodeBody(result.Remain, ctx, &remain))
+
+	if remain.Hello != nil {
+		t.Errorf("hello must be hidden from the remaining body, but got %q", *remain.Hello)
+	}
+
+	if remain.Intval != 1 {
+		t.Errorf("unexpected intval: %d", remain.Intval)
+	}
+}
+
+func TestGohclIntegration_JustAttributes(t *testing.T) {
+	failOnError := FailOnError(t, map[string]*hcl.File{})
+
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+str1: "x${var.one}y"
+
+str2: !!exp upper(var.one)
+
+int1: 1
+`)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.MapVal(map[string]cty.Value{
+				"one": cty.StringVal("one"),
+			}),
+		},
+		Functions: Functions("."),
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	failOnError(diags)
+
+	f := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	got := map[string]string{}
+
+	f(gohcl.DecodeBody(file.Body, ctx, &got))
+
+	want := map[string]string{
+		"str1": "xoney",
+		"str2": "ONE",
+		"int1": "1",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	exprs := map[string]hcl.Expression{}
+
+	f(gohcl.DecodeBody(file.Body, ctx, &exprs))
+
+	if len(exprs) != 3 {
+		t.Errorf("unexpected number of expressions: want 3, got %d", len(exprs))
+	}
+}
+
+func TestGohclIntegration_JustAttributes_InvalidKey(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+? [a, b]
+: c
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	_, diags = file.Body.JustAttributes()
+
+	if !diags.HasErrors() {
+		t.Fatal("expected an error for a non-scalar key, got none")
+	}
+}
+
+func TestGohclIntegration_MalformedNestedValues(t *testing.T) {
+	fileName := "example.yaml"
+
+	testcases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "invalid expression in mapping",
+			yaml: `
+map1:
+  foo: !!exp 1 +
+`,
+		},
+		{
+			name: "invalid expression in sequence",
+			yaml: `
+map1:
+- !!exp 1 +
+`,
+		},
+		{
+			name: "invalid template in nested mapping",
+			yaml: `
+map1:
+  foo:
+    bar: "x${"
+`,
+		},
+		{
+			name: "unsupported tag in sequence in mapping",
+			yaml: `
+map1:
+- foo: !unknown bar
+`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), fileName)
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			var result struct {
+				Map1 cty.Value `hcl:"map1,attr"`
+			}
+
+			diags = gohcl.DecodeBody(file.Body, nil, &result)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error, got none")
+			}
+
+			for _, d := range diags {
+				if d.Subject == nil || d.Subject.Filename != fileName {
+					t.Errorf("expected diagnostic to point at %s, got %v", fileName, d.Subject)
+				}
+			}
+		})
+	}
+}
+
+func TestGohclIntegration_VariablesOfMalformedNestedValues(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+map1:
+  foo: !!exp 1 +
+  bar: "x${var.one}y"
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+	if !diags.HasErrors() {
+		t.Fatal("expected an error for the malformed value, got none")
+	}
+
+	vars := attrs["map1"].Expr.Variables()
+
+	if len(vars) != 1 || vars[0].RootName() != "var" {
+		t.Errorf("unexpected variables: %v", vars)
+	}
+}
+
+func TestGohclIntegration_NestedSequences(t *testing.T) {
+	failOnError := FailOnError(t, map[string]*hcl.File{})
+
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+map1:
+  http: [80, 8080]
+  https:
+  - 443
+
+map2:
+  foo:
+    bar:
+    - [a, b]
+    - ["x${var.one}y"]
+
+ary1:
+- names: [a, b]
+- names:
+  - c
+`)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.MapVal(map[string]cty.Value{
+				"one": cty.StringVal("ONE"),
+			}),
+		},
+	}
+
+	type Static struct {
+		Map1 map[string][]int                 `hcl:"map1,attr"`
+		Map2 map[string]map[string][][]string `hcl:"map2,attr"`
+		Ary1 []map[string][]string            `hcl:"ary1,attr"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	failOnError(diags)
+
+	f := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	var got Static
+
+	f(gohcl.DecodeBody(file.Body, ctx, &got))
+
+	want := Static{
+		Map1: map[string][]int{"http": {80, 8080}, "https": {443}},
+		Map2: map[string]map[string][][]string{"foo": {"bar": {{"a", "b"}, {"xONEy"}}}},
+		Ary1: []map[string][]string{{"names": {"a", "b"}}, {"names": {"c"}}},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	var dynamic struct {
+		Map1 cty.Value `hcl:"map1,attr"`
+		Map2 cty.Value `hcl:"map2,attr"`
+		Ary1 cty.Value `hcl:"ary1,attr"`
+	}
+
+	f(gohcl.DecodeBody(file.Body, ctx, &dynamic))
+
+	wantMap1 := cty.ObjectVal(map[string]cty.Value{
+		"http":  cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(8080)}),
+		"https": cty.TupleVal([]cty.Value{cty.NumberIntVal(443)}),
+	})
+
+	if !dynamic.Map1.RawEquals(wantMap1) {
+		t.Errorf("unexpected map1: want %#v, got %#v", wantMap1, dynamic.Map1)
+	}
+
+	wantMap2 := cty.ObjectVal(map[string]cty.Value{
+		"foo": cty.ObjectVal(map[string]cty.Value{
+			"bar": cty.TupleVal([]cty.Value{
+				cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+				cty.TupleVal([]cty.Value{cty.StringVal("xONEy")}),
+			}),
+		}),
+	})
+
+	if !dynamic.Map2.RawEquals(wantMap2) {
+		t.Errorf("unexpected map2: want %#v, got %#v", wantMap2, dynamic.Map2)
+	}
+
+	wantAry1 := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"names": cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		}),
+		cty.ObjectVal(map[string]cty.Value{
+			"names": cty.TupleVal([]cty.Value{cty.StringVal("c")}),
+		}),
+	})
+
+	if !dynamic.Ary1.RawEquals(wantAry1) {
+		t.Errorf("unexpected ary1: want %#v, got %#v", wantAry1, dynamic.Ary1)
+	}
+}
+
+func TestGohclIntegration_HeterogeneousCollections(t *testing.T) {
+	failOnError := FailOnError(t, map[string]*hcl.File{})
+
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+map1:
+  name: foo
+  replicas: 3
+  ports: [80, "http"]
+
+map2: {}
+
+ary1: [1, "a"]
+
+ary2: []
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	failOnError(diags)
+
+	f := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	var dynamic struct {
+		Map1 cty.Value `hcl:"map1,attr"`
+		Map2 cty.Value `hcl:"map2,attr"`
+		Ary1 cty.Value `hcl:"ary1,attr"`
+		Ary2 cty.Value `hcl:"ary2,attr"`
+	}
+
+	f(gohcl.DecodeBody(file.Body, nil, &dynamic))
+
+	wantMap1 := cty.ObjectVal(map[string]cty.Value{
+		"name":     cty.StringVal("foo"),
+		"replicas": cty.NumberIntVal(3),
+		"ports":    cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.StringVal("http")}),
+	})
+
+	if !dynamic.Map1.RawEquals(wantMap1) {
+		t.Errorf("unexpected map1: want %#v, got %#v", wantMap1, dynamic.Map1)
+	}
+
+	if !dynamic.Map2.RawEquals(cty.EmptyObjectVal) {
+		t.Errorf("unexpected map2: want %#v, got %#v", cty.EmptyObjectVal, dynamic.Map2)
+	}
+
+	wantAry1 := cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.StringVal("a")})
+
+	if !dynamic.Ary1.RawEquals(wantAry1) {
+		t.Errorf("unexpected ary1: want %#v, got %#v", wantAry1, dynamic.Ary1)
+	}
+
+	if !dynamic.Ary2.RawEquals(cty.EmptyTupleVal) {
+		t.Errorf("unexpected ary2: want %#v, got %#v", cty.EmptyTupleVal, dynamic.Ary2)
+	}
+
+	type Static struct {
+		Map1 struct {
+			Name     string   `cty:"name"`
+			Replicas int      `cty:"replicas"`
+			Ports    []string `cty:"ports"`
+		} `hcl:"map1,attr"`
+		Map2 map[string]string `hcl:"map2,attr"`
+		Ary1 []string          `hcl:"ary1,attr"`
+		Ary2 []int             `hcl:"ary2,attr"`
+	}
+
+	var got Static
+
+	f(gohcl.DecodeBody(file.Body, nil, &got))
+
+	var want Static
+
+	want.Map1.Name = "foo"
+	want.Map1.Replicas = 3
+	want.Map1.Ports = []string{"80", "http"}
+	want.Map2 = map[string]string{}
+	want.Ary1 = []string{"1", "a"}
+	want.Ary2 = []int{}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestGohclIntegration_ScalarTags(t *testing.T) {
+	failOnError := FailOnError(t, map[string]*hcl.File{})
+
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+bool1: true
+bool2: False
+bool3: !!bool yes
+float1: 0.5
+float2: 1.5e3
+float3: .inf
+float4: -.Inf
+null1: null
+null2: ~
+null3:
+int1: 0x1F
+int2: 0o17
+int3: 1_000
+int4: -12
+int5: 123456789012345678901234567890
+timestamp1: 2001-12-14
+timestamp2: 2001-12-14t21:59:43.10-05:00
+binary1: !!binary |
+  aGVs
+  bG8=
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	failOnError(diags)
+
+	f := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	got := map[string]cty.Value{}
+
+	f(gohcl.DecodeBody(file.Body, nil, &got))
+
+	bigInt, _ := cty.ParseNumberVal("123456789012345678901234567890")
+
+	want := map[string]cty.Value{
+		"bool1":      cty.True,
+		"bool2":      cty.False,
+		"bool3":      cty.True,
+		"float1":     cty.NumberFloatVal(0.5),
+		"float2":     cty.NumberIntVal(1500),
+		"float3":     cty.PositiveInfinity,
+		"float4":     cty.NegativeInfinity,
+		"null1":      cty.NullVal(cty.DynamicPseudoType),
+		"null2":      cty.NullVal(cty.DynamicPseudoType),
+		"null3":      cty.NullVal(cty.DynamicPseudoType),
+		"int1":       cty.NumberIntVal(31),
+		"int2":       cty.NumberIntVal(15),
+		"int3":       cty.NumberIntVal(1000),
+		"int4":       cty.NumberIntVal(-12),
+		"int5":       bigInt,
+		"timestamp1": cty.StringVal("2001-12-14T00:00:00Z"),
+		"timestamp2": cty.StringVal("2001-12-14T21:59:43.1-05:00"),
+		"binary1":    cty.StringVal("aGVsbG8="),
+	}
+
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			t.Errorf("missing %s", k)
+			continue
+		}
+
+		if !g.Type().Equals(w.Type()) || !g.Equals(w).True() {
+			t.Errorf("unexpected value for %s: want %#v, got %#v", k, w, g)
+		}
+	}
+
+	var result struct {
+		Int5   string   `hcl:"int5,attr"`
+		Remain hcl.Body `hcl:",remain"`
+	}
+
+	f(gohcl.DecodeBody(file.Body, nil, &result))
+
+	if result.Int5 != "123456789012345678901234567890" {
+		t.Errorf("large integers must not lose precision: got %s", result.Int5)
+	}
+}
+
+func TestGohclIntegration_InvalidScalars(t *testing.T) {
+	fileName := "example.yaml"
+
+	for _, src := range []string{
+		"v: .nan\n",
+		"v: !!bool maybe\n",
+		"v: !!int 1.5\n",
+		"v: !!binary '*'\n",
+		"v: !!timestamp yesterday\n",
+		"v: !custom foo\n",
+	} {
+		file, diags := hcl2yaml.Parse([]byte(src), fileName)
+
+		FailOnError(t, map[string]*hcl.File{})(diags)
+
+		_, diags = file.Body.JustAttributes()
+		if !diags.HasErrors() {
+			t.Errorf("expected an error for %q, got none", src)
+		}
+	}
+}
+
 // Functions is
 func Functions(baseDir string) map[string]function.Function {
 	return map[string]function.Function{
@@ -324,3 +839,55 @@ func Functions(baseDir string) map[string]function.Function {
 		"convert":          typeexpr.ConvertFunc,
 	}
 }
+
+func TestGohclIntegration_Heredoc(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+a: !!exp |
+  <<EOT
+  hello
+  EOT
+b: !!exp |
+  <<-EOT
+    hello
+      world
+    EOT
+c: !!exp |
+  <<EOT
+  x${var.one}y
+  EOT
+`)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"one": cty.StringVal("ONE"),
+			}),
+		},
+	}
+
+	type Result struct {
+		A string `hcl:"a,attr"`
+		B string `hcl:"b,attr"`
+		C string `hcl:"c,attr"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	var got Result
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(gohcl.DecodeBody(file.Body, ctx, &got))
+
+	want := Result{
+		A: "hello\n",
+		B: "hello\n  world\n",
+		C: "xONEy\n",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,94 @@
+package integration
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"testing"
+)
+
+// TestHcldec_Errors verifies that bodies with errors still return their content, as HCL callers like hcldec
+// and hcl.MergeFiles rely on it, rather than panicking.
+func TestHcldec_Errors(t *testing.T) {
+	testcases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "invalid expression",
+			yaml: `
+a: 1
+b: !!exp foo +
+`,
+		},
+		{
+			name: "missing required attribute",
+			yaml: `
+a: 1
+`,
+		},
+		{
+			name: "singular and plural forms",
+			yaml: `
+a: 1
+svc:
+  name: web
+svcs:
+- name: db
+`,
+		},
+		{
+			name: "invalid block",
+			yaml: `
+a: 1
+svc: foo
+`,
+		},
+	}
+
+	spec := hcldec.ObjectSpec{
+		"a": &hcldec.AttrSpec{Name: "a", Type: cty.Number},
+		"b": &hcldec.AttrSpec{Name: "b", Type: cty.DynamicPseudoType},
+		"c": &hcldec.AttrSpec{Name: "c", Type: cty.String, Required: true},
+		"svc": &hcldec.BlockListSpec{
+			TypeName: "svc",
+			Nested: hcldec.ObjectSpec{
+				"name": &hcldec.AttrSpec{Name: "name", Type: cty.String},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), "example.yaml")
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			_, diags = hcldec.Decode(file.Body, spec, nil)
+			if !diags.HasErrors() {
+				t.Error("expected an error from hcldec.Decode")
+			}
+
+			_, _, diags = hcldec.PartialDecode(file.Body, spec, nil)
+			if !diags.HasErrors() {
+				t.Error("expected an error from hcldec.PartialDecode")
+			}
+
+			hcldec.Variables(file.Body, spec)
+
+			other, diags := hcl2yaml.Parse([]byte("d: 2\n"), "other.yaml")
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			content, diags := hcl.MergeFiles([]*hcl.File{file, other}).Content(hcldec.ImpliedSchema(spec))
+			if !diags.HasErrors() {
+				t.Error("expected an error from the merged body")
+			}
+
+			if content == nil || content.Attributes["a"] == nil {
+				t.Errorf("expected the content of the merged body to contain the valid attribute, got %v", content)
+			}
+		})
+	}
+}
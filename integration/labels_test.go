@@ -0,0 +1,370 @@
+package integration
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type labelsResource struct {
+	Type string `hcl:"type,label"`
+	Name string `hcl:"name,label"`
+	Ami  string `hcl:"ami,attr"`
+}
+
+type labelsConfig struct {
+	Resources []labelsResource `hcl:"resource,block"`
+}
+
+func TestLabels(t *testing.T) {
+	testcases := []struct {
+		name string
+		yaml string
+		opts []hcl2yaml.ParserOption
+		want []labelsResource
+	}{
+		{
+			name: "field style",
+			yaml: `
+resources:
+- type: aws_instance
+  name: web
+  ami: ami-1
+- type: aws_instance
+  name: db
+  ami: ami-2
+`,
+			want: []labelsResource{
+				{Type: "aws_instance", Name: "web", Ami: "ami-1"},
+				{Type: "aws_instance", Name: "db", Ami: "ami-2"},
+			},
+		},
+		{
+			name: "map style",
+			yaml: `
+resource:
+  aws_instance:
+    web:
+      ami: ami-1
+    db:
+      ami: ami-2
+  aws_eip:
+    ip:
+      ami: ami-3
+`,
+			want: []labelsResource{
+				{Type: "aws_instance", Name: "web", Ami: "ami-1"},
+				{Type: "aws_instance", Name: "db", Ami: "ami-2"},
+				{Type: "aws_eip", Name: "ip", Ami: "ami-3"},
+			},
+		},
+		{
+			name: "map style with sequence of bodies",
+			yaml: `
+resource:
+  aws_instance:
+    web:
+    - ami: ami-1
+    - ami: ami-2
+`,
+			want: []labelsResource{
+				{Type: "aws_instance", Name: "web", Ami: "ami-1"},
+				{Type: "aws_instance", Name: "web", Ami: "ami-2"},
+			},
+		},
+		{
+			name: "map style in plural form",
+			yaml: `
+resources:
+- aws_instance:
+    web:
+      ami: ami-1
+- aws_instance:
+    db:
+      ami: ami-2
+`,
+			want: []labelsResource{
+				{Type: "aws_instance", Name: "web", Ami: "ami-1"},
+				{Type: "aws_instance", Name: "db", Ami: "ami-2"},
+			},
+		},
+		{
+			name: "forced map style",
+			yaml: `
+resource:
+  type:
+    name:
+      ami: ami-1
+  name:
+    web:
+      ami: ami-2
+`,
+			opts: []hcl2yaml.ParserOption{hcl2yaml.WithLabelStyle(hcl2yaml.LabelStyleMap)},
+			want: []labelsResource{
+				{Type: "type", Name: "name", Ami: "ami-1"},
+				{Type: "name", Name: "web", Ami: "ami-2"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.NewParser(tc.opts...).ParseYAML([]byte(tc.yaml), "example.yaml")
+
+			files := map[string]*hcl.File{"example.yaml": file}
+
+			FailOnError(t, files)(diags)
+
+			var config labelsConfig
+
+			FailOnError(t, files)(gohcl.DecodeBody(file.Body, nil, &config))
+
+			if diff := cmp.Diff(tc.want, config.Resources); diff != "" {
+				t.Errorf("unexpected diff:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLabels_DepthMismatch(t *testing.T) {
+	testcases := []struct {
+		name    string
+		yaml    string
+		opts    []hcl2yaml.ParserOption
+		summary string
+		detail  string
+		line    int
+	}{
+		{
+			name: "too shallow",
+			yaml: `
+resource:
+  aws_instance:
+    ami: ami-1
+`,
+			summary: "Invalid block body",
+			detail:  "but got a scalar. A resource block has 2 label(s): type, name",
+			line:    4,
+		},
+		{
+			name: "empty",
+			yaml: `
+resource:
+  aws_instance: {}
+`,
+			summary: "Missing block label",
+			detail:  "whose keys represent the resource block's name",
+			line:    3,
+		},
+		{
+			name: "misspelled label field",
+			yaml: `
+resource:
+  type: aws_instance
+  nme: web
+  ami: ami-1
+`,
+			summary: `Value for label "name" not found`,
+			line:    3,
+		},
+		{
+			name: "forced field style",
+			yaml: `
+resource:
+  aws_instance:
+    web:
+      ami: ami-1
+`,
+			opts:    []hcl2yaml.ParserOption{hcl2yaml.WithLabelStyle(hcl2yaml.LabelStyleField)},
+			summary: `Value for label "type" not found`,
+			line:    3,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.NewParser(tc.opts...).ParseYAML([]byte(tc.yaml), "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			var config labelsConfig
+
+			diags = gohcl.DecodeBody(file.Body, nil, &config)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error")
+			}
+
+			d := diags[0]
+
+			if !strings.HasPrefix(d.Summary, tc.summary) || !strings.Contains(d.Detail, tc.detail) {
+				t.Errorf("unexpected diagnostic: %s: %s", d.Summary, d.Detail)
+			}
+
+			if d.Subject == nil || d.Subject.Start.Line != tc.line {
+				t.Errorf("unexpected subject: %v", d.Subject)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyIntoMap_LabelStyle(t *testing.T) {
+	yamlSource := []byte(`
+resource:
+  type:
+    name:
+      ami: ami-1
+  name:
+    web:
+      ami: ami-2
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := hcl2yaml.MapSchema{
+		Blocks: map[string]hcl2yaml.Block{
+			"resource": {
+				LabelNames: []string{"type", "name"},
+				LabelStyle: hcl2yaml.LabelStyleMap,
+				Attributes: map[string]hcl2yaml.Attribute{
+					"ami": {Kind: reflect.String},
+				},
+			},
+		},
+	}
+
+	m := map[string]interface{}{}
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, m))
+
+	want := map[string]interface{}{
+		"resource": []interface{}{
+			map[string]interface{}{"type": "type", "name": "name", "ami": "ami-1"},
+			map[string]interface{}{"type": "name", "name": "web", "ami": "ami-2"},
+		},
+	}
+
+	if diff := cmp.Diff(want, m); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestLabels_FieldStyleBody(t *testing.T) {
+	yamlSource := []byte(`
+resource:
+  type: aws_instance
+  name: web
+  ami: ami-1
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "resource", LabelNames: []string{"type", "name"}}},
+	}
+
+	content, diags := file.Body.Content(schema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	block := content.Blocks[0]
+
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if len(attrs) != 1 || attrs["ami"] == nil {
+		t.Errorf("expected the body to contain only ami, got %v", attrs)
+	}
+
+	want := []hcl.Range{
+		{Filename: "example.yaml", Start: hcl.Pos{Line: 3, Column: 9, Byte: 19}, End: hcl.Pos{Line: 3, Column: 21, Byte: 31}},
+		{Filename: "example.yaml", Start: hcl.Pos{Line: 4, Column: 9, Byte: 40}, End: hcl.Pos{Line: 4, Column: 12, Byte: 43}},
+	}
+
+	if diff := cmp.Diff(want, block.LabelRanges); diff != "" {
+		t.Errorf("unexpected label ranges:\n%s", diff)
+	}
+}
+
+func TestLabels_InvalidLabel(t *testing.T) {
+	testcases := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "expression",
+			yaml: `
+resource:
+  type: !!exp var.type
+  name: web
+`,
+		},
+		{
+			name: "integer",
+			yaml: `
+resource:
+  type: aws_instance
+  name: 1
+`,
+		},
+		{
+			name: "mapping",
+			yaml: `
+resource:
+  type: aws_instance
+  name: {}
+`,
+		},
+		{
+			name: "map-style integer",
+			yaml: `
+resource:
+  aws_instance:
+    3:
+      ami: ami-123
+`,
+		},
+		{
+			name: "map-style expression",
+			yaml: `
+resource:
+  !!exp var.type:
+    web:
+      ami: ami-123
+`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			var config labelsConfig
+
+			diags = gohcl.DecodeBody(file.Body, nil, &config)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error")
+			}
+
+			if diags[0].Summary != "Invalid block label" {
+				t.Errorf("unexpected diagnostic: %s: %s", diags[0].Summary, diags[0].Detail)
+			}
+		})
+	}
+}
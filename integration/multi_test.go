@@ -0,0 +1,231 @@
+package integration
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"strings"
+	"testing"
+)
+
+func TestParseMulti(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`kind: Deployment
+name: web
+---
+kind: Service
+name: web-svc
+---
+`)
+
+	files, diags := hcl2yaml.ParseMulti(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	if len(files) != 3 {
+		t.Fatalf("unexpected number of files: want 3, got %d", len(files))
+	}
+
+	type Resource struct {
+		Kind string  `hcl:"kind,attr"`
+		Name *string `hcl:"name,attr"`
+	}
+
+	var got []Resource
+
+	for _, file := range files[:2] {
+		var r Resource
+
+		FailOnError(t, map[string]*hcl.File{fileName: file})(gohcl.DecodeBody(file.Body, nil, &r))
+
+		got = append(got, r)
+	}
+
+	web, webSvc := "web", "web-svc"
+
+	want := []Resource{
+		{Kind: "Deployment", Name: &web},
+		{Kind: "Service", Name: &webSvc},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	attrs, diags := files[1].Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: files[1]})(diags)
+
+	if r := attrs["kind"].Expr.Range(); r.Start.Line != 4 || r.Start.Byte != 37 {
+		t.Errorf("expected the range to be relative to the beginning of the stream, got %v", r)
+	}
+
+	attrs, diags = files[2].Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: files[2]})(diags)
+
+	if len(attrs) != 0 {
+		t.Errorf("expected no attributes for the empty document, got %v", attrs)
+	}
+}
+
+func TestParseMulti_MergeFiles(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+foo:
+  name: a
+---
+foo:
+  name: b
+bar: BAR
+`)
+
+	files, diags := hcl2yaml.ParseMulti(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	type Foo struct {
+		Name string `hcl:"name,attr"`
+	}
+
+	type Result struct {
+		Foos []Foo  `hcl:"foo,block"`
+		Bar  string `hcl:"bar,attr"`
+	}
+
+	var got Result
+
+	FailOnError(t, map[string]*hcl.File{fileName: files[0]})(gohcl.DecodeBody(hcl.MergeFiles(files), nil, &got))
+
+	want := Result{
+		Foos: []Foo{{Name: "a"}, {Name: "b"}},
+		Bar:  "BAR",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+// TestParseMulti_MergeFilesError verifies that an error in one of the documents is reported by the merged body,
+// along with the content of the other documents.
+func TestParseMulti_MergeFilesError(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+foo:
+  name: a
+baz: BAZ
+---
+foo:
+  name: b
+bar: !!exp b +
+`)
+
+	files, diags := hcl2yaml.ParseMulti(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "bar"}, {Name: "baz"}},
+		Blocks:     []hcl.BlockHeaderSchema{{Type: "foo"}},
+	}
+
+	content, diags := hcl.MergeFiles(files).Content(schema)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error, got none")
+	}
+
+	if diags[0].Subject == nil || diags[0].Subject.Start.Line != 8 {
+		t.Errorf("expected the diagnostic to point at the second document, got %v", diags[0].Subject)
+	}
+
+	if content == nil || len(content.Blocks) != 2 || content.Attributes["baz"] == nil {
+		t.Errorf("expected the content of the valid parts of the documents, got %v", content)
+	}
+
+	type Foo struct {
+		Name string `hcl:"name,attr"`
+	}
+
+	var got struct {
+		Foos []Foo  `hcl:"foo,block"`
+		Bar  string `hcl:"bar,attr"`
+		Baz  string `hcl:"baz,attr"`
+	}
+
+	if diags := gohcl.DecodeBody(hcl.MergeFiles(files), nil, &got); !diags.HasErrors() {
+		t.Error("expected an error from gohcl, got none")
+	}
+}
+
+func TestParseMulti_Error(t *testing.T) {
+	yamlSource := []byte(`
+foo: a
+---
+foo: [b
+`)
+
+	_, diags := hcl2yaml.ParseMulti(yamlSource, "example.yaml")
+	if !diags.HasErrors() {
+		t.Fatal("expected an error, got none")
+	}
+
+	if !strings.Contains(diags[0].Detail, "index 1") {
+		t.Errorf("expected the diagnostic to mention the document index, got %q", diags[0].Detail)
+	}
+}
+
+func TestParser_ParseYAMLMulti(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+size: !size 3
+---
+size: !size 5
+`)
+
+	p := hcl2yaml.NewParser(
+		hcl2yaml.WithTag("!size", func(v string) (cty.Value, error) {
+			return cty.StringVal(v + "GiB"), nil
+		}),
+	)
+
+	files, diags := p.ParseYAMLMulti(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	type Result struct {
+		Size string `hcl:"size,attr"`
+	}
+
+	var got []string
+
+	for _, file := range files {
+		var r Result
+
+		FailOnError(t, p.Files())(gohcl.DecodeBody(file.Body, nil, &r))
+
+		got = append(got, r.Size)
+	}
+
+	if diff := cmp.Diff([]string{"3GiB", "5GiB"}, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	if f := p.Files()[fileName]; f == nil || string(f.Bytes) != string(yamlSource) {
+		t.Errorf("expected the stream to be registered to the parser, got %v", f)
+	}
+
+	again, diags := p.ParseYAMLMulti(yamlSource, fileName)
+
+	FailOnError(t, p.Files())(diags)
+
+	if len(again) != len(files) || again[0] != files[0] || again[1] != files[1] {
+		t.Errorf("expected the same files to be returned for the same filename")
+	}
+}
@@ -0,0 +1,201 @@
+package integration
+
+import (
+	"bytes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"strings"
+	"testing"
+)
+
+func TestNavigation(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`foo:
+- fooFirstLabel: bar
+  baz: !!exp var.missing
+map1:
+  list:
+  - a: !!exp var.missing
+`)
+
+	type Foo struct {
+		FooFirstLabel string `hcl:"fooFirstLabel,label"`
+		Baz           string `hcl:"baz,attr"`
+	}
+
+	type Result struct {
+		Foos []Foo          `hcl:"foo,block"`
+		Map1 hcl.Expression `hcl:"map1,attr"`
+	}
+
+	schema, _ := gohcl.ImpliedBodySchema(&Result{})
+
+	testcases := []struct {
+		name    string
+		opts    []hcl2yaml.ParserOption
+		context string
+	}{
+		{
+			name:    "navigation schema",
+			opts:    []hcl2yaml.ParserOption{hcl2yaml.WithNavigationSchema(schema)},
+			context: `foo "bar"`,
+		},
+		{
+			name:    "no navigation schema",
+			opts:    nil,
+			context: "foo[0]",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.NewParser(tc.opts...).ParseYAML(yamlSource, fileName)
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			files := map[string]*hcl.File{fileName: file}
+
+			nav, ok := file.Nav.(interface {
+				ContextString(offset int) string
+				ContextDefRange(offset int) hcl.Range
+			})
+			if !ok {
+				t.Fatalf("unexpected type of Nav: %T", file.Nav)
+			}
+
+			bazOffset := strings.Index(string(yamlSource), "baz:")
+
+			before := nav.ContextString(bazOffset)
+
+			var result Result
+
+			diags = gohcl.DecodeBody(file.Body, nil, &result)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error, got none")
+			}
+
+			var buf bytes.Buffer
+
+			hcl.NewDiagnosticTextWriter(&buf, files, 80, false).WriteDiagnostics(diags)
+
+			if !strings.Contains(buf.String(), "on example.yaml line 3, in "+tc.context+":") {
+				t.Errorf("expected the diagnostic to contain the context, got:\n%s", buf.String())
+			}
+
+			// The navigation is built when the source is parsed, so decoding never changes it.
+			if after := nav.ContextString(bazOffset); after != before || after != tc.context {
+				t.Errorf("unexpected context string: %q before decoding, and %q after decoding", before, after)
+			}
+
+			offset := strings.Index(string(yamlSource), "a: !!exp")
+
+			if got := nav.ContextString(offset); got != "map1.list[0]" {
+				t.Errorf("unexpected context string: %q", got)
+			}
+
+			// The block is an item of the sequence, so it is defined at the first key of the item.
+			if got := nav.ContextDefRange(bazOffset); got.Start.Line != 2 || got.Start.Column != 3 {
+				t.Errorf("unexpected context def range: %v", got)
+			}
+		})
+	}
+}
+
+// TestNavigation_EndOfValue verifies that the context is found for diagnostics pointing at the very end of a value,
+// like the ones for an unterminated function call.
+func TestNavigation_EndOfValue(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`resource:
+  aws:
+    web:
+      ami: !!exp foo(`)
+
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "resource", LabelNames: []string{"type", "name"}}},
+	}
+
+	testcases := []struct {
+		name    string
+		opts    []hcl2yaml.ParserOption
+		context string
+	}{
+		{
+			name:    "navigation schema",
+			opts:    []hcl2yaml.ParserOption{hcl2yaml.WithNavigationSchema(schema)},
+			context: `resource "aws" "web"`,
+		},
+		{
+			name:    "no navigation schema",
+			opts:    nil,
+			context: "resource.aws.web",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.NewParser(tc.opts...).ParseYAML(yamlSource, fileName)
+
+			FailOnError(t, map[string]*hcl.File{})(diags)
+
+			content, diags := file.Body.Content(schema)
+
+			FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+			_, diags = content.Blocks[0].Body.JustAttributes()
+			if !diags.HasErrors() {
+				t.Fatal("expected an error, got none")
+			}
+
+			var buf bytes.Buffer
+
+			hcl.NewDiagnosticTextWriter(&buf, map[string]*hcl.File{fileName: file}, 80, false).WriteDiagnostics(diags)
+
+			if !strings.Contains(buf.String(), "on example.yaml line 4, in "+tc.context+":") {
+				t.Errorf("expected the diagnostic to contain the context, got:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestNavigation_NestedBlocks(t *testing.T) {
+	yamlSource := []byte(`service:
+  web:
+    container:
+    - name: nginx
+      image: nginx
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	nav := file.Nav.(interface {
+		ContextString(offset int) string
+		ContextDefRange(offset int) hcl.Range
+	})
+
+	testcases := []struct {
+		at      string
+		context string
+		defLine int
+	}{
+		{at: "image:", context: "service.web.container[0]", defLine: 4},
+		{at: "container:", context: "service.web.container", defLine: 3},
+		{at: "web:", context: "service.web", defLine: 2},
+	}
+
+	for _, tc := range testcases {
+		offset := strings.Index(string(yamlSource), tc.at)
+
+		if got := nav.ContextString(offset); got != tc.context {
+			t.Errorf("unexpected context string at %q: %q", tc.at, got)
+		}
+
+		if got := nav.ContextDefRange(offset); got.Start.Line != tc.defLine {
+			t.Errorf("unexpected context def range at %q: %v", tc.at, got)
+		}
+	}
+}
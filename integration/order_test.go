@@ -0,0 +1,169 @@
+package integration
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"reflect"
+	"testing"
+)
+
+func TestContent_SourceOrder(t *testing.T) {
+	yamlSource := []byte(`
+c:
+  name: c1
+a:
+  name: a1
+bs:
+- name: b1
+- name: b2
+d:
+  name: d1
+`)
+
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "a", LabelNames: []string{"name"}},
+			{Type: "b", LabelNames: []string{"name"}},
+			{Type: "c", LabelNames: []string{"name"}},
+			{Type: "d", LabelNames: []string{"name"}},
+		},
+	}
+
+	want := []string{"c c1", "a a1", "b b1", "b b2", "d d1"}
+
+	for i := 0; i < 20; i++ {
+		file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+
+		content, diags := file.Body.Content(schema)
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+
+		var got []string
+		for _, b := range content.Blocks {
+			got = append(got, fmt.Sprintf("%s %s", b.Type, b.Labels[0]))
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("unexpected order of blocks in run %d:\n%s", i, diff)
+		}
+	}
+}
+
+func TestDecodeBodyIntoMap_Deterministic(t *testing.T) {
+	yamlSource := []byte(`
+hello: world
+intval: 1
+foos:
+- name: foo1
+  baz: BAZ1
+- name: foo2
+  baz: BAZ2
+bar:
+  qux: QUX
+`)
+
+	schema := hcl2yaml.MapSchema{
+		Attributes: map[string]hcl2yaml.Attribute{
+			"hello":  {Kind: reflect.String},
+			"intval": {Kind: reflect.Int},
+		},
+		Blocks: map[string]hcl2yaml.Block{
+			"foo": {
+				LabelNames: []string{"name"},
+				Attributes: map[string]hcl2yaml.Attribute{
+					"baz": {Kind: reflect.String},
+				},
+			},
+			"bar": {
+				Attributes: map[string]hcl2yaml.Attribute{
+					"qux": {Kind: reflect.String},
+				},
+			},
+		},
+	}
+
+	decode := func() (map[string]interface{}, []string) {
+		file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+
+		m := map[string]interface{}{}
+
+		diags = hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, m)
+
+		FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+		var summaries []string
+		for _, d := range diags {
+			summaries = append(summaries, d.Summary)
+		}
+
+		return m, summaries
+	}
+
+	wantResult, wantDiags := decode()
+
+	foos := wantResult["foo"].([]interface{})
+	if foos[0].(map[string]interface{})["name"] != "foo1" || foos[1].(map[string]interface{})["name"] != "foo2" {
+		t.Fatalf("unexpected order of foo blocks: %v", foos)
+	}
+
+	for i := 0; i < 20; i++ {
+		result, diags := decode()
+
+		if diff := cmp.Diff(wantResult, result); diff != "" {
+			t.Fatalf("unexpected result in run %d:\n%s", i, diff)
+		}
+
+		if diff := cmp.Diff(wantDiags, diags); diff != "" {
+			t.Fatalf("unexpected diagnostics in run %d:\n%s", i, diff)
+		}
+	}
+}
+
+func TestMappingExpression_FirstErrorInSourceOrder(t *testing.T) {
+	yamlSource := []byte(`
+m:
+  j: !!exp var.j
+  i: !!exp var.i
+  h: !!exp var.h
+  g: !!exp var.g
+  f: !!exp var.f
+  e: !!exp var.e
+  d: !!exp var.d
+  c: !!exp var.c
+  b: !!exp var.b
+  a: !!exp var.a
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{}}
+
+	for i := 0; i < 20; i++ {
+		_, diags := attrs["m"].Expr.Value(ctx)
+		if !diags.HasErrors() {
+			t.Fatal("expected an error for the unknown variables")
+		}
+
+		if line := diags[0].Subject.Start.Line; line != 3 {
+			t.Fatalf("expected the first error to be the one of the first key on line 3 in run %d, got line %d: %s", i, line, diags.Error())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"bytes"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcl2yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "example.yaml")
+
+	if err := ioutil.WriteFile(fileName, []byte("hello: world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := hcl2yaml.NewParser()
+
+	file, diags := p.ParseYAMLFile(fileName)
+
+	FailOnError(t, p.Files())(diags)
+
+	again, diags := p.ParseYAMLFile(fileName)
+
+	FailOnError(t, p.Files())(diags)
+
+	if file != again {
+		t.Error("expected the parsed file to be cached")
+	}
+
+	if p.Files()[fileName] != file {
+		t.Errorf("expected Files to contain %s", fileName)
+	}
+
+	if string(p.Sources()[fileName]) != "hello: world\n" {
+		t.Errorf("unexpected source: %q", p.Sources()[fileName])
+	}
+
+	var result struct {
+		Hello string `hcl:"hello,attr"`
+	}
+
+	FailOnError(t, p.Files())(gohcl.DecodeBody(file.Body, nil, &result))
+
+	if result.Hello != "world" {
+		t.Errorf("unexpected hello: %q", result.Hello)
+	}
+
+	_, diags = p.ParseYAMLFile(filepath.Join(dir, "missing.yaml"))
+	if !diags.HasErrors() {
+		t.Error("expected an error for the missing file, got none")
+	}
+}
+
+func TestParser_Options(t *testing.T) {
+	yamlSource := []byte(`
+env: !env HOME
+secret: !vault secret/foo
+`)
+
+	fileName := "example.yaml"
+
+	var debug bytes.Buffer
+
+	p := hcl2yaml.NewParser(
+		hcl2yaml.Strict(false),
+		hcl2yaml.WithTag("!env", func(v string) (cty.Value, error) {
+			return cty.StringVal("/home/" + strings.ToLower(v)), nil
+		}),
+		hcl2yaml.WithDebugWriter(&debug),
+	)
+
+	file, diags := p.ParseYAML(yamlSource, fileName)
+
+	FailOnError(t, p.Files())(diags)
+
+	var result struct {
+		Env    string `hcl:"env,attr"`
+		Secret string `hcl:"secret,attr"`
+	}
+
+	FailOnError(t, p.Files())(gohcl.DecodeBody(file.Body, nil, &result))
+
+	if result.Env != "/home/home" {
+		t.Errorf("unexpected env: %q", result.Env)
+	}
+
+	if result.Secret != "secret/foo" {
+		t.Errorf("unexpected secret: %q", result.Secret)
+	}
+
+	if !strings.Contains(debug.String(), `"Tag": "!vault"`) {
+		t.Errorf("expected the debug writer to receive the yaml node tree, got %q", debug.String())
+	}
+
+	strict := hcl2yaml.NewParser()
+
+	file, diags = strict.ParseYAML(yamlSource, fileName)
+
+	FailOnError(t, strict.Files())(diags)
+
+	diags = gohcl.DecodeBody(file.Body, nil, &result)
+	if !diags.HasErrors() {
+		t.Error("expected an error for the unsupported tag in strict mode, got none")
+	}
+}
+
+func TestParse_NoOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	_, diags := hcl2yaml.Parse([]byte("hello: world\n"), "example.yaml")
+
+	os.Stdout = stdout
+	w.Close()
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 0 {
+		t.Errorf("expected Parse not to write to stdout, got %q", out)
+	}
+}
@@ -0,0 +1,330 @@
+package integration
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type pluralizationService struct {
+	Name string `hcl:"name,attr"`
+}
+
+type pluralizationConfig struct {
+	Services []pluralizationService `hcl:"service,block"`
+}
+
+func TestPluralization(t *testing.T) {
+	testcases := []struct {
+		name  string
+		yaml  string
+		names []string
+	}{
+		{
+			name: "singular",
+			yaml: `
+service:
+  name: web
+`,
+			names: []string{"web"},
+		},
+		{
+			name: "plural",
+			yaml: `
+services:
+- name: web
+- name: db
+`,
+			names: []string{"web", "db"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse([]byte(tc.yaml), "example.yaml")
+
+			files := map[string]*hcl.File{"example.yaml": file}
+
+			FailOnError(t, files)(diags)
+
+			var config pluralizationConfig
+
+			FailOnError(t, files)(gohcl.DecodeBody(file.Body, nil, &config))
+
+			var names []string
+			for _, s := range config.Services {
+				names = append(names, s.Name)
+			}
+
+			if !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("unexpected services: expected %v, got %v", tc.names, names)
+			}
+		})
+	}
+}
+
+func TestPluralization_BothDefined(t *testing.T) {
+	yamlSource := []byte(`
+service:
+  name: web
+services:
+- name: db
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	var config pluralizationConfig
+
+	diags = gohcl.DecodeBody(file.Body, nil, &config)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.Contains(diags[0].Summary, `Both "service" and "services" are defined`) {
+		t.Errorf("unexpected summary: %s", diags[0].Summary)
+	}
+
+	if diags[0].Subject == nil || diags[0].Subject.Start.Line != 4 {
+		t.Errorf("expected the diagnostic to point at the plural key, got %v", diags[0].Subject)
+	}
+}
+
+func TestPluralization_Pluralizer(t *testing.T) {
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "index"}},
+	}
+
+	yamlSource := []byte(`
+index: {}
+indexes:
+- {}
+indices:
+- {}
+- {}
+`)
+
+	testcases := []struct {
+		name   string
+		opts   []hcl2yaml.ParserOption
+		blocks int
+	}{
+		{
+			name:   "english",
+			opts:   nil,
+			blocks: 0,
+		},
+		{
+			name:   "explicit plural",
+			opts:   []hcl2yaml.ParserOption{hcl2yaml.WithPluralizer(hcl2yaml.EnglishPluralizer(map[string]string{"index": "indices"}))},
+			blocks: 0,
+		},
+		{
+			name:   "disabled",
+			opts:   []hcl2yaml.ParserOption{hcl2yaml.WithPluralizer(nil)},
+			blocks: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.NewParser(tc.opts...).ParseYAML(yamlSource, "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			content, _, diags := file.Body.PartialContent(schema)
+
+			if tc.blocks == 0 {
+				if !diags.HasErrors() {
+					t.Fatal("expected an error, as both the singular and the plural forms are defined")
+				}
+
+				return
+			}
+
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			if len(content.Blocks) != tc.blocks {
+				t.Errorf("unexpected number of blocks: expected %d, got %d", tc.blocks, len(content.Blocks))
+			}
+		})
+	}
+
+	t.Run("explicit plural only", func(t *testing.T) {
+		p := hcl2yaml.NewParser(hcl2yaml.WithPluralizer(hcl2yaml.EnglishPluralizer(map[string]string{"index": "indices"})))
+
+		file, diags := p.ParseYAML([]byte("indices:\n- {}\n- {}\n"), "example.yaml")
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+
+		content, diags := file.Body.Content(schema)
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+
+		if len(content.Blocks) != 2 {
+			t.Errorf("unexpected number of blocks: expected 2, got %d", len(content.Blocks))
+		}
+	})
+}
+
+// TestPluralization_ExplicitBlockType verifies that a block type named after the plural form of another block type,
+// or an attribute named so, takes precedence over the plural form regardless of the order of the schema.
+func TestPluralization_ExplicitBlockType(t *testing.T) {
+	yamlSource := []byte(`
+rules:
+  x: 1
+`)
+
+	rule := hcl.BlockHeaderSchema{Type: "rule"}
+	rules := hcl.BlockHeaderSchema{Type: "rules"}
+
+	testcases := []struct {
+		name   string
+		schema *hcl.BodySchema
+		block  string
+	}{
+		{
+			name:   "singular first",
+			schema: &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{rule, rules}},
+			block:  "rules",
+		},
+		{
+			name:   "plural first",
+			schema: &hcl.BodySchema{Blocks: []hcl.BlockHeaderSchema{rules, rule}},
+			block:  "rules",
+		},
+		{
+			name: "attribute",
+			schema: &hcl.BodySchema{
+				Attributes: []hcl.AttributeSchema{{Name: "rules"}},
+				Blocks:     []hcl.BlockHeaderSchema{rule},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			content, diags := file.Body.Content(tc.schema)
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			if tc.block == "" {
+				if len(content.Blocks) != 0 || content.Attributes["rules"] == nil {
+					t.Errorf("expected the rules attribute, got %v", content)
+				}
+
+				return
+			}
+
+			if len(content.Blocks) != 1 || content.Blocks[0].Type != tc.block {
+				t.Errorf("expected a %s block, got %v", tc.block, content.Blocks)
+			}
+		})
+	}
+}
+
+func TestEnglishPluralizer(t *testing.T) {
+	pluralize := hcl2yaml.EnglishPluralizer(map[string]string{"index": "indices"})
+
+	testcases := map[string]string{
+		"service": "services",
+		"policy":  "policies",
+		"key":     "keys",
+		"bus":     "buses",
+		"box":     "boxes",
+		"match":   "matches",
+		"person":  "people",
+		"index":   "indices",
+	}
+
+	for singular, expected := range testcases {
+		if actual := pluralize(singular); actual != expected {
+			t.Errorf("unexpected plural of %q: expected %q, got %q", singular, expected, actual)
+		}
+	}
+}
+
+func TestDecodeBodyIntoMap_Plural(t *testing.T) {
+	yamlSource := []byte(`
+indices:
+- name: a
+- name: b
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := hcl2yaml.MapSchema{
+		Blocks: map[string]hcl2yaml.Block{
+			"index": {
+				Plural: "indices",
+				Attributes: map[string]hcl2yaml.Attribute{
+					"name": {Kind: reflect.String},
+				},
+			},
+		},
+	}
+
+	m := map[string]interface{}{}
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, m))
+
+	indices, ok := m["indices"].([]interface{})
+	if !ok || len(indices) != 2 {
+		t.Fatalf("unexpected result: %v", m)
+	}
+}
+
+// TestDecodeBodyIntoMap_ModifiedPlural verifies that decoding the same file with a plural form changed in between
+// uses the new one, as the compiled schemas are cached by the contents of the schemas.
+func TestDecodeBodyIntoMap_ModifiedPlural(t *testing.T) {
+	yamlSource := []byte(`
+indices:
+- name: a
+- name: b
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := hcl2yaml.MapSchema{
+		Blocks: map[string]hcl2yaml.Block{
+			"index": {
+				Plural: "indices",
+				Attributes: map[string]hcl2yaml.Attribute{
+					"name": {Kind: reflect.String},
+				},
+			},
+		},
+	}
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, map[string]interface{}{}))
+
+	b := schema.Blocks["index"]
+	b.Plural = "indexes"
+	schema.Blocks["index"] = b
+
+	if diags := hcl2yaml.DecodeBodyIntoMap(nil, file.Body, schema, map[string]interface{}{}); !diags.HasErrors() {
+		t.Error("expected an error for the plural form removed from the schema")
+	}
+}
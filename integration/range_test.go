@@ -0,0 +1,367 @@
+package integration
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"strings"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`map1:
+  foo: FOO
+  bar:
+  - 1
+  - 2
+seq1: [a, "b"]
+str1: |
+  line1
+  line2
+
+str2: "x${var.one}y"
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+	pos := func(line, column, byte int) hcl.Pos {
+		return hcl.Pos{Line: line, Column: column, Byte: byte}
+	}
+
+	want := map[string]hcl.Range{
+		"map1": {Filename: fileName, Start: pos(2, 3, 8), End: pos(5, 6, 35)},
+		"seq1": {Filename: fileName, Start: pos(6, 7, 42), End: pos(6, 15, 50)},
+	}
+
+	for name, w := range want {
+		attr, ok := attrs[name]
+		if !ok {
+			t.Fatalf("attribute %q not found", name)
+		}
+
+		if diff := cmp.Diff(w, attr.Expr.Range()); diff != "" {
+			t.Errorf("unexpected range for %q:\n%s", name, diff)
+		}
+	}
+}
+
+func TestMissingItemRange(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`foo:
+  baz: BAZ
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	type Foo struct {
+		Baz string `hcl:"baz,attr"`
+		Qux string `hcl:"qux,attr"`
+	}
+
+	type Result struct {
+		Foo Foo `hcl:"foo,block"`
+	}
+
+	var result Result
+
+	diags = gohcl.DecodeBody(file.Body, nil, &result)
+
+	if !diags.HasErrors() {
+		t.Fatal("expected an error for the missing required attribute, got none")
+	}
+
+	want := &hcl.Range{
+		Filename: fileName,
+		Start:    hcl.Pos{Line: 1, Column: 1, Byte: 0},
+		End:      hcl.Pos{Line: 1, Column: 4, Byte: 3},
+	}
+
+	if diff := cmp.Diff(want, diags[0].Subject); diff != "" {
+		t.Errorf("unexpected subject:\n%s", diff)
+	}
+}
+
+func TestExpressionDiagnosticRanges(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`str1: "x${var.one}y"
+int1: !!exp 1 + var.two
+int2: !!exp |
+  var.two +
+  1
+str2: 'x${var.two}y'
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"one": cty.StringVal("ONE"),
+			}),
+		},
+	}
+
+	pos := func(line, column, byte int) hcl.Pos {
+		return hcl.Pos{Line: line, Column: column, Byte: byte}
+	}
+
+	if diff := cmp.Diff(hcl.Range{Filename: fileName, Start: pos(1, 7, 6), End: pos(1, 21, 20)}, attrs["str1"].Expr.Range()); diff != "" {
+		t.Errorf("unexpected range for str1:\n%s", diff)
+	}
+
+	want := map[string]hcl.Range{
+		"int1": {Filename: fileName, Start: pos(2, 20, 40), End: pos(2, 24, 44)},
+		"int2": {Filename: fileName, Start: pos(4, 6, 64), End: pos(4, 10, 68)},
+		"str2": {Filename: fileName, Start: pos(6, 14, 88), End: pos(6, 18, 92)},
+	}
+
+	for name, w := range want {
+		_, diags := attrs[name].Expr.Value(ctx)
+		if !diags.HasErrors() {
+			t.Fatalf("expected an error for %q, got none", name)
+		}
+
+		if diff := cmp.Diff(&w, diags[0].Subject); diff != "" {
+			t.Errorf("unexpected subject for %q:\n%s", name, diff)
+		}
+	}
+}
+
+func TestAttributeAndBlockRanges(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`hello: world
+map1:
+  foo: FOO
+resource:
+  type: aws_instance
+  name: web
+  ami: ami-1
+bars:
+- baz: 1
+- baz: 2
+base: &b hello
+ref: *b
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "hello"}, {Name: "map1"}, {Name: "base"}, {Name: "ref"}},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "resource", LabelNames: []string{"type", "name"}},
+			{Type: "bar"},
+		},
+	}
+
+	content, diags := file.Body.Content(schema)
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+	pos := func(line, column, byte int) hcl.Pos {
+		return hcl.Pos{Line: line, Column: column, Byte: byte}
+	}
+
+	rng := func(start, end hcl.Pos) hcl.Range {
+		return hcl.Range{Filename: fileName, Start: start, End: end}
+	}
+
+	type attrRanges struct {
+		Range     hcl.Range
+		NameRange hcl.Range
+	}
+
+	wantAttrs := map[string]attrRanges{
+		"hello": {Range: rng(pos(1, 1, 0), pos(1, 13, 12)), NameRange: rng(pos(1, 1, 0), pos(1, 6, 5))},
+		"map1":  {Range: rng(pos(2, 1, 13), pos(3, 11, 29)), NameRange: rng(pos(2, 1, 13), pos(2, 5, 17))},
+		"base":  {Range: rng(pos(11, 1, 110), pos(11, 15, 124)), NameRange: rng(pos(11, 1, 110), pos(11, 5, 114))},
+		// The range of an aliased value ends at the alias, not at the anchored value.
+		"ref": {Range: rng(pos(12, 1, 125), pos(12, 8, 132)), NameRange: rng(pos(12, 1, 125), pos(12, 4, 128))},
+	}
+
+	for name, w := range wantAttrs {
+		attr, ok := content.Attributes[name]
+		if !ok {
+			t.Fatalf("attribute %q not found", name)
+		}
+
+		if diff := cmp.Diff(w, attrRanges{Range: attr.Range, NameRange: attr.NameRange}); diff != "" {
+			t.Errorf("unexpected ranges for %q:\n%s", name, diff)
+		}
+	}
+
+	type blockRanges struct {
+		DefRange    hcl.Range
+		TypeRange   hcl.Range
+		LabelRanges []hcl.Range
+	}
+
+	wantBlocks := []blockRanges{
+		{
+			DefRange:  rng(pos(4, 1, 30), pos(4, 9, 38)),
+			TypeRange: rng(pos(4, 1, 30), pos(4, 9, 38)),
+			LabelRanges: []hcl.Range{
+				rng(pos(5, 9, 48), pos(5, 21, 60)),
+				rng(pos(6, 9, 69), pos(6, 12, 72)),
+			},
+		},
+		{
+			DefRange:  rng(pos(9, 3, 94), pos(9, 6, 97)),
+			TypeRange: rng(pos(8, 1, 86), pos(8, 5, 90)),
+		},
+		{
+			DefRange:  rng(pos(10, 3, 103), pos(10, 6, 106)),
+			TypeRange: rng(pos(8, 1, 86), pos(8, 5, 90)),
+		},
+	}
+
+	var gotBlocks []blockRanges
+
+	for _, typ := range []string{"resource", "bar"} {
+		for _, b := range content.Blocks.OfType(typ) {
+			gotBlocks = append(gotBlocks, blockRanges{DefRange: b.DefRange, TypeRange: b.TypeRange, LabelRanges: b.LabelRanges})
+		}
+	}
+
+	if diff := cmp.Diff(wantBlocks, gotBlocks); diff != "" {
+		t.Errorf("unexpected block ranges:\n%s", diff)
+	}
+}
+
+func TestStringExpressionRanges(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`a: foo
+  bar
+b: "x\ty"
+c: >
+  folded
+  lines
+d: plain
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+	pos := func(line, column, byte int) hcl.Pos {
+		return hcl.Pos{Line: line, Column: column, Byte: byte}
+	}
+
+	want := map[string]hcl.Range{
+		"a": {Filename: fileName, Start: pos(1, 4, 3), End: pos(2, 6, 12)},
+		"b": {Filename: fileName, Start: pos(3, 4, 16), End: pos(3, 10, 22)},
+		"c": {Filename: fileName, Start: pos(4, 4, 26), End: pos(6, 8, 44)},
+		"d": {Filename: fileName, Start: pos(7, 4, 48), End: pos(7, 9, 53)},
+	}
+
+	for name, w := range want {
+		if diff := cmp.Diff(w, attrs[name].Expr.Range()); diff != "" {
+			t.Errorf("unexpected range for %q:\n%s", name, diff)
+		}
+
+		if diff := cmp.Diff(w, attrs[name].Expr.StartRange()); diff != "" {
+			t.Errorf("unexpected start range for %q:\n%s", name, diff)
+		}
+	}
+}
+
+// TestBlockScalarDiagnosticRanges verifies that the diagnostics for the later lines of block scalars point at
+// the source, although yaml.v3 strips the indentation from the values the expressions are parsed from.
+func TestBlockScalarDiagnosticRanges(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`g: !!exp |
+    1 +
+    var.nope + 1
+t: |
+    a
+      b ${var.nope}
+f: >
+    x
+    ${var.nope}
+h: !!exp |
+  <<EOT
+    ${var.nope}
+  EOT
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{fileName: file})(diags)
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.EmptyObjectVal,
+		},
+	}
+
+	for _, name := range []string{"g", "t", "f", "h"} {
+		t.Run(name, func(t *testing.T) {
+			_, diags := attrs[name].Expr.Value(ctx)
+			if !diags.HasErrors() {
+				t.Fatal("expected an error, got none")
+			}
+
+			subject := diags[0].Subject
+
+			if got := string(yamlSource[subject.Start.Byte:subject.End.Byte]); got != ".nope" {
+				t.Errorf("expected the diagnostic to point at .nope, got %q at %v", got, subject)
+			}
+
+			line := 1 + strings.Count(string(yamlSource[:subject.Start.Byte]), "\n")
+			if subject.Start.Line != line {
+				t.Errorf("unexpected line of the subject: expected %d, got %v", line, subject)
+			}
+		})
+	}
+
+	t.Run("parse error", func(t *testing.T) {
+		src := []byte("g: !!exp |\n    1 +\n    2 ]\n")
+
+		file, diags := hcl2yaml.Parse(src, fileName)
+
+		FailOnError(t, map[string]*hcl.File{})(diags)
+
+		_, diags = file.Body.JustAttributes()
+		if !diags.HasErrors() {
+			t.Fatal("expected an error, got none")
+		}
+
+		subject := diags[0].Subject
+
+		if got := string(src[subject.Start.Byte:subject.End.Byte]); got != "]" || subject.Start.Line != 3 || subject.Start.Column != 7 {
+			t.Errorf("expected the diagnostic to point at ], got %q at %v", got, subject)
+		}
+	})
+}
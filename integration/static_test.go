@@ -0,0 +1,194 @@
+package integration
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"strings"
+	"testing"
+)
+
+func TestStaticExpressions(t *testing.T) {
+	fileName := "example.yaml"
+
+	yamlSource := []byte(`
+depends_on: [aws_instance.web, "aws_instance.db[0]"]
+ignore_changes:
+- tags
+- ami
+no_dependencies: []
+lifecycle:
+  create_before_destroy: true
+  on_failure: continue
+replace_triggered_by: !!exp "[aws_instance.web.id]"
+template: "${var.x}"
+`)
+
+	type Result struct {
+		DependsOn          hcl.Expression `hcl:"depends_on"`
+		IgnoreChanges      hcl.Expression `hcl:"ignore_changes"`
+		NoDependencies     hcl.Expression `hcl:"no_dependencies"`
+		Lifecycle          hcl.Expression `hcl:"lifecycle"`
+		ReplaceTriggeredBy hcl.Expression `hcl:"replace_triggered_by"`
+		Template           hcl.Expression `hcl:"template"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, fileName)
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	failOnError := FailOnError(t, map[string]*hcl.File{fileName: file})
+
+	var result Result
+
+	failOnError(gohcl.DecodeBody(file.Body, nil, &result))
+
+	traversalStrings := func(expr hcl.Expression) []string {
+		t.Helper()
+
+		exprs, diags := hcl.ExprList(expr)
+		failOnError(diags)
+
+		strs := []string{}
+
+		for _, e := range exprs {
+			traversal, diags := hcl.AbsTraversalForExpr(e)
+			failOnError(diags)
+
+			var s strings.Builder
+
+			s.WriteString(traversal.RootName())
+
+			for _, step := range traversal[1:] {
+				switch step := step.(type) {
+				case hcl.TraverseAttr:
+					s.WriteString("." + step.Name)
+				case hcl.TraverseIndex:
+					s.WriteString("[" + step.Key.GoString() + "]")
+				}
+			}
+
+			strs = append(strs, s.String())
+		}
+
+		return strs
+	}
+
+	t.Run("ExprList and AbsTraversalForExpr", func(t *testing.T) {
+		want := []string{"aws_instance.web", "aws_instance.db[cty.NumberIntVal(0)]"}
+
+		if diff := cmp.Diff(want, traversalStrings(result.DependsOn)); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if diff := cmp.Diff([]string{"aws_instance.web.id"}, traversalStrings(result.ReplaceTriggeredBy)); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if diff := cmp.Diff([]string{}, traversalStrings(result.NoDependencies)); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("traversal ranges", func(t *testing.T) {
+		exprs, diags := hcl.ExprList(result.DependsOn)
+		failOnError(diags)
+
+		traversal, diags := hcl.AbsTraversalForExpr(exprs[1])
+		failOnError(diags)
+
+		want := hcl.Range{
+			Filename: fileName,
+			Start:    hcl.Pos{Line: 2, Column: 33, Byte: 33},
+			End:      hcl.Pos{Line: 2, Column: 51, Byte: 51},
+		}
+
+		if diff := cmp.Diff(want, traversal.SourceRange()); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("ExprAsKeyword", func(t *testing.T) {
+		exprs, diags := hcl.ExprList(result.IgnoreChanges)
+		failOnError(diags)
+
+		var keywords []string
+
+		for _, e := range exprs {
+			keywords = append(keywords, hcl.ExprAsKeyword(e))
+		}
+
+		if diff := cmp.Diff([]string{"tags", "ami"}, keywords); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if kw := hcl.ExprAsKeyword(result.Template); kw != "" {
+			t.Errorf("unexpected keyword for a template: %q", kw)
+		}
+	})
+
+	t.Run("ExprMap", func(t *testing.T) {
+		pairs, diags := hcl.ExprMap(result.Lifecycle)
+		failOnError(diags)
+
+		var keys, values []string
+
+		for _, p := range pairs {
+			keys = append(keys, hcl.ExprAsKeyword(p.Key))
+			values = append(values, hcl.ExprAsKeyword(p.Value))
+
+			key, diags := p.Key.Value(nil)
+			failOnError(diags)
+
+			if hcl.ExprAsKeyword(p.Key) != key.AsString() {
+				t.Errorf("unexpected value of the key %q: %q", hcl.ExprAsKeyword(p.Key), key.AsString())
+			}
+		}
+
+		if diff := cmp.Diff([]string{"create_before_destroy", "on_failure"}, keys); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if diff := cmp.Diff([]string{"", "continue"}, values); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if r := pairs[1].Key.Range(); r.Start.Line != 9 || r.Start.Column != 3 {
+			t.Errorf("unexpected range of the key: %v", r)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, diags := hcl.AbsTraversalForExpr(result.Template); !diags.HasErrors() {
+			t.Error("expected an error for a template")
+		}
+
+		if _, diags := hcl.ExprList(result.Lifecycle); !diags.HasErrors() {
+			t.Error("expected an error for a mapping")
+		}
+
+		if _, diags := hcl.ExprMap(result.DependsOn); !diags.HasErrors() {
+			t.Error("expected an error for a sequence")
+		}
+	})
+
+	t.Run("values are unchanged", func(t *testing.T) {
+		var got struct {
+			DependsOn     []string          `hcl:"depends_on"`
+			IgnoreChanges []string          `hcl:"ignore_changes"`
+			Lifecycle     map[string]string `hcl:"lifecycle"`
+			Remain        hcl.Body          `hcl:",remain"`
+		}
+
+		failOnError(gohcl.DecodeBody(file.Body, nil, &got))
+
+		if diff := cmp.Diff([]string{"aws_instance.web", "aws_instance.db[0]"}, got.DependsOn); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		if diff := cmp.Diff(map[string]string{"create_before_destroy": "true", "on_failure": "continue"}, got.Lifecycle); diff != "" {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"reflect"
+	"testing"
+)
+
+func templateEvalContext() *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"n":    cty.NumberIntVal(3),
+				"list": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+				"obj": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("web"),
+					"port": cty.NumberIntVal(80),
+				}),
+				"enabled": cty.True,
+			}),
+		},
+	}
+}
+
+// TestTemplate_SingleInterpolation verifies that a string consisting of a single interpolation evaluates to
+// the interpolated value as-is, as in HCL native syntax, rather than to the value converted to a string.
+func TestTemplate_SingleInterpolation(t *testing.T) {
+	yamlSource := []byte(`
+quoted_number: "${var.n}"
+plain_number: ${var.n}
+list: "${var.list}"
+object: "${var.obj}"
+bool: "${var.enabled}"
+nested:
+  port: "${var.obj.port}"
+  names: ["${var.list}"]
+interpolated: "n=${var.n}"
+padded: " ${var.n}"
+escaped: "$${var.n}"
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	attrs, diags := file.Body.JustAttributes()
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+	ctx := templateEvalContext()
+
+	testcases := []struct {
+		name string
+		want cty.Value
+	}{
+		{name: "quoted_number", want: cty.NumberIntVal(3)},
+		{name: "plain_number", want: cty.NumberIntVal(3)},
+		{name: "list", want: cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})},
+		{name: "object", want: cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("web"), "port": cty.NumberIntVal(80)})},
+		{name: "bool", want: cty.True},
+		{name: "nested", want: cty.ObjectVal(map[string]cty.Value{
+			"port":  cty.NumberIntVal(80),
+			"names": cty.TupleVal([]cty.Value{cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})}),
+		})},
+		{name: "interpolated", want: cty.StringVal("n=3")},
+		{name: "padded", want: cty.StringVal(" 3")},
+		{name: "escaped", want: cty.StringVal("${var.n}")},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, diags := attrs[tc.name].Expr.Value(ctx)
+
+			FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+			if !got.RawEquals(tc.want) {
+				t.Errorf("unexpected value: want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTemplate_SingleInterpolationDecode(t *testing.T) {
+	yamlSource := []byte(`
+replicas: "${var.n}"
+names: "${var.list}"
+service:
+  name: "${var.obj.name}"
+  port: "${var.obj.port}"
+  enabled: "${var.enabled}"
+`)
+
+	type Service struct {
+		Name    string `hcl:"name"`
+		Port    int    `hcl:"port"`
+		Enabled bool   `hcl:"enabled"`
+	}
+
+	type Result struct {
+		Replicas int      `hcl:"replicas"`
+		Names    []string `hcl:"names"`
+		Service  Service  `hcl:"service,block"`
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	failOnError := FailOnError(t, map[string]*hcl.File{"example.yaml": file})
+
+	var got Result
+
+	failOnError(gohcl.DecodeBody(file.Body, templateEvalContext(), &got))
+
+	want := Result{
+		Replicas: 3,
+		Names:    []string{"a", "b"},
+		Service:  Service{Name: "web", Port: 80, Enabled: true},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestDecodeBodyIntoMap_SingleInterpolation(t *testing.T) {
+	yamlSource := []byte(`
+replicas: "${var.n}"
+service:
+  name: "${var.obj.name}"
+  port: "${var.obj.port}"
+`)
+
+	schema := hcl2yaml.MapSchema{
+		Attributes: map[string]hcl2yaml.Attribute{
+			"replicas": {Kind: reflect.Int},
+		},
+		Blocks: map[string]hcl2yaml.Block{
+			"service": {
+				Attributes: map[string]hcl2yaml.Attribute{
+					"name": {Kind: reflect.String},
+					"port": {Kind: reflect.Int},
+				},
+			},
+		},
+	}
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+
+	FailOnError(t, map[string]*hcl.File{})(diags)
+
+	got := map[string]interface{}{}
+
+	diags = hcl2yaml.DecodeBodyIntoMap(templateEvalContext(), file.Body, schema, got)
+
+	FailOnError(t, map[string]*hcl.File{"example.yaml": file})(diags)
+
+	want := map[string]interface{}{
+		"replicas": 3,
+		"service": []interface{}{
+			map[string]interface{}{"name": "web", "port": 80},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,191 @@
+package integration
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/mumoshu/hcl2-yaml"
+	"github.com/zclconf/go-cty/cty"
+	"strings"
+	"testing"
+)
+
+func TestContent_UnsupportedKeys(t *testing.T) {
+	yamlSource := []byte(`
+replicsa: 3
+servce:
+  name: web
+unknown: foo
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "replicas"}},
+		Blocks:     []hcl.BlockHeaderSchema{{Type: "service"}},
+	}
+
+	_, diags = file.Body.Content(schema)
+
+	want := []struct {
+		summary string
+		detail  string
+		line    int
+	}{
+		{
+			summary: "Unsupported argument",
+			detail:  `An argument named "replicsa" is not expected here. Did you mean "replicas"?`,
+			line:    2,
+		},
+		{
+			summary: "Unsupported block type",
+			detail:  `Blocks of type "servce" are not expected here. Did you mean "service"?`,
+			line:    3,
+		},
+		{
+			summary: "Unsupported argument",
+			detail:  `An argument named "unknown" is not expected here.`,
+			line:    5,
+		},
+	}
+
+	if len(diags) != len(want) {
+		t.Fatalf("unexpected number of diagnostics: expected %d, got %d: %v", len(want), len(diags), diags)
+	}
+
+	for i, w := range want {
+		d := diags[i]
+
+		if d.Summary != w.summary || d.Detail != w.detail {
+			t.Errorf("unexpected diagnostic #%d: expected %q: %q, got %q: %q", i, w.summary, w.detail, d.Summary, d.Detail)
+		}
+
+		if d.Subject == nil || d.Subject.Start.Line != w.line || d.Subject.Start.Column != 1 {
+			t.Errorf("unexpected subject of diagnostic #%d: %v", i, d.Subject)
+		}
+	}
+
+	_, remain, diags := file.Body.PartialContent(schema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	attrs, diags := remain.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	for _, name := range []string{"replicsa", "servce", "unknown"} {
+		if _, ok := attrs[name]; !ok {
+			t.Errorf("expected %s to be left to the remaining body", name)
+		}
+	}
+}
+
+func TestMappingExpression_NonScalarKey(t *testing.T) {
+	yamlSource := []byte(`
+labels:
+  ? [a, b]
+  : c
+  d: e
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if !diags.HasErrors() {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.HasPrefix(diags[0].Summary, "Unexpected key kind") {
+		t.Errorf("unexpected diagnostic: %s: %s", diags[0].Summary, diags[0].Detail)
+	}
+
+	if diags[0].Subject == nil || diags[0].Subject.Start.Line != 3 {
+		t.Errorf("unexpected subject: %v", diags[0].Subject)
+	}
+
+	if _, diags := attrs["labels"].Expr.Value(nil); !diags.HasErrors() {
+		t.Error("expected the mapping to fail to evaluate, rather than to have an empty key")
+	}
+}
+
+// TestContent_ModifiedSchema verifies that a schema modified after decoding a body is honored on the next decode.
+func TestContent_ModifiedSchema(t *testing.T) {
+	yamlSource := []byte(`
+replicas: 3
+image: nginx
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "replicas"}},
+	}
+
+	content, _, diags := file.Body.PartialContent(schema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if len(content.Attributes) != 1 {
+		t.Errorf("unexpected attributes: %v", content.Attributes)
+	}
+
+	schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: "image", Required: true})
+
+	content, diags = file.Body.Content(schema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if len(content.Attributes) != 2 || content.Attributes["image"] == nil {
+		t.Errorf("unexpected attributes: %v", content.Attributes)
+	}
+
+	schema.Attributes = schema.Attributes[:1]
+
+	if _, diags := file.Body.Content(schema); !diags.HasErrors() {
+		t.Error("expected an error for the attribute removed from the schema")
+	}
+}
+
+// TestContent_UnsupportedKeysHcldec verifies that unsupported keys are reported to hcldec callers,
+// along with the content of the supported ones.
+func TestContent_UnsupportedKeysHcldec(t *testing.T) {
+	yamlSource := []byte(`
+replicsa: 3
+image: nginx
+`)
+
+	file, diags := hcl2yaml.Parse(yamlSource, "example.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	spec := hcldec.ObjectSpec{
+		"replicas": &hcldec.AttrSpec{Name: "replicas", Type: cty.Number},
+		"image":    &hcldec.AttrSpec{Name: "image", Type: cty.String},
+	}
+
+	val, diags := hcldec.Decode(file.Body, spec, nil)
+	if len(diags) != 1 || diags[0].Summary != "Unsupported argument" {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if !strings.Contains(diags[0].Detail, `Did you mean "replicas"?`) {
+		t.Errorf("unexpected detail: %s", diags[0].Detail)
+	}
+
+	if got := val.GetAttr("image"); !got.RawEquals(cty.StringVal("nginx")) {
+		t.Errorf("unexpected value of image: %#v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// LabelStyle is how the labels of a block are written in YAML.
+type LabelStyle int
+
+const (
+	// LabelStyleAuto detects the style of each block. Labels are read from nested keys when the mapping lacks
+	// some of the keys named after the label names and all its values are mappings, and from fields otherwise.
+	LabelStyleAuto LabelStyle = iota
+
+	// LabelStyleField reads labels from the fields named after the label names, like:
+	//
+	//   resource:
+	//     type: aws_instance
+	//     name: web
+	//     ami: ami-123
+	LabelStyleField
+
+	// LabelStyleMap reads labels from the keys of nested mappings, one nesting level per label
+	// as HCL's JSON syntax does, like:
+	//
+	//   resource:
+	//     aws_instance:
+	//       web:
+	//         ami: ami-123
+	LabelStyleMap
+)
+
+// labelStyle returns the label style of the block of the type written as the indexed YAML mapping.
+// It is never LabelStyleAuto.
+func (f *yamlBody) labelStyle(tpe string, blockSchema hcl.BlockHeaderSchema, index *mappingIndex) LabelStyle {
+	if len(blockSchema.LabelNames) == 0 {
+		return LabelStyleField
+	}
+
+	style := f.src.labelStyle
+
+	if s, ok := f.schema.labelStyles[tpe]; ok {
+		style = s
+	}
+
+	if style != LabelStyleAuto {
+		return style
+	}
+
+	for _, label := range blockSchema.LabelNames {
+		if _, ok := index.get(label); !ok {
+			return detectLabelStyle(blockSchema, index)
+		}
+	}
+
+	return LabelStyleField
+}
+
+// detectLabelStyle returns LabelStyleMap only when the mapping looks like the first nesting level of labels,
+// that is every value is a mapping, or a sequence of block bodies for a block with a single label.
+// Otherwise a misspelled label field like `nme: web` would be read as a label, and reported as an invalid block body
+// rather than as the missing label.
+func detectLabelStyle(blockSchema hcl.BlockHeaderSchema, index *mappingIndex) LabelStyle {
+	if len(index.entries) == 0 {
+		return LabelStyleField
+	}
+
+	for _, e := range index.entries {
+		switch e.value.Kind {
+		case yaml.MappingNode:
+		case yaml.SequenceNode:
+			if len(blockSchema.LabelNames) > 1 {
+				return LabelStyleField
+			}
+		default:
+			return LabelStyleField
+		}
+	}
+
+	return LabelStyleMap
+}
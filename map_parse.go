@@ -8,7 +8,7 @@ import (
 func DecodeBodyIntoMap(ctx *hcl.EvalContext, body hcl.Body, schema MapSchema, result interface{}) hcl.Diagnostics {
 	bodySchema := schema.BodySchema()
 
-	bodyContent, diags := body.Content(bodySchema)
+	bodyContent, diags := bodyContentOf(body, bodySchema, schema.Blocks)
 
 	if diags.HasErrors() {
 		return diags
@@ -42,6 +42,17 @@ func DecodeBodyIntoMap(ctx *hcl.EvalContext, body hcl.Body, schema MapSchema, re
 	return nil
 }
 
+// bodyContentOf returns the content of the body, letting YAML bodies take the plural forms and the label styles
+// of block types given by the schema into account.
+func bodyContentOf(body hcl.Body, bodySchema *hcl.BodySchema, blocks map[string]Block) (*hcl.BodyContent, hcl.Diagnostics) {
+	yamlBody, ok := body.(*YamlBody)
+	if !ok {
+		return body.Content(bodySchema)
+	}
+
+	return yamlBody.withSchema(bodySchema, blocks).content()
+}
+
 func parseMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent, schema MapSchema, dest map[string]interface{}) hcl.Diagnostics {
 	if diags := parseBlocksIntoMap(ctx, bodyContent, schema.Blocks, dest); diags.HasErrors() {
 		return diags
@@ -3,6 +3,7 @@ package hcl2yaml
 import (
 	"github.com/hashicorp/hcl/v2"
 	"reflect"
+	"sort"
 )
 
 type MapSchema struct {
@@ -17,7 +18,9 @@ func (m *MapSchema) BodySchema() *hcl.BodySchema {
 func createBodySchema(as map[string]Attribute, bs map[string]Block) *hcl.BodySchema {
 	attrs := []hcl.AttributeSchema{}
 
-	for k, v := range as {
+	for _, k := range sortedAttributeNames(as) {
+		v := as[k]
+
 		attrs = append(attrs, hcl.AttributeSchema{
 			Name:     k,
 			Required: !v.Optional,
@@ -26,7 +29,9 @@ func createBodySchema(as map[string]Attribute, bs map[string]Block) *hcl.BodySch
 
 	blocks := []hcl.BlockHeaderSchema{}
 
-	for k, v := range bs {
+	for _, k := range sortedBlockTypes(bs) {
+		v := bs[k]
+
 		blocks = append(blocks, hcl.BlockHeaderSchema{
 			Type:       k,
 			LabelNames: v.LabelNames,
@@ -41,6 +46,32 @@ func createBodySchema(as map[string]Attribute, bs map[string]Block) *hcl.BodySch
 	return bodySchema
 }
 
+// sortedAttributeNames returns the attribute names in order, so that decoding is deterministic.
+func sortedAttributeNames(as map[string]Attribute) []string {
+	var names []string
+
+	for k := range as {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// sortedBlockTypes returns the block types in order, so that decoding is deterministic.
+func sortedBlockTypes(bs map[string]Block) []string {
+	var types []string
+
+	for k := range bs {
+		types = append(types, k)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
 type Block struct {
 	Plural string
 
@@ -48,6 +79,17 @@ type Block struct {
 
 	Singleton bool
 
+	// LabelStyle is how the labels of the blocks are written, which overrides the one of the parser.
+	LabelStyle LabelStyle
+
+	// Required makes it an error for the block to be absent. It is the same as setting MinItems to 1.
+	Required bool
+
+	// MinItems and MaxItems are the minimum and the maximum number of blocks of the type.
+	// Zero means unlimited.
+	MinItems int
+	MaxItems int
+
 	Blocks     map[string]Block
 	Attributes map[string]Attribute
 }
@@ -0,0 +1,167 @@
+package hcl2yaml
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// navigation is the implementation of hcl.File.Nav for YAML files.
+//
+// The YAML source alone doesn't tell which keys are blocks and which are attributes, as that depends on the schema.
+// So the contexts are the top-level blocks of the navigation schema given to the parser, named after their headers
+// like `foo "bar"` as in native HCL, and otherwise the mappings and sequences in the source, named after the paths of
+// YAML keys to them like `foo.bar[0]` as in HCL's JSON syntax. They are collected once when the source is parsed,
+// so that the answers never depend on how the file has been decoded so far.
+type navigation struct {
+	src *source
+
+	// blocks are the top-level blocks of the navigation schema.
+	blocks []navigationContext
+
+	// contexts are the mappings and sequences in the source. Every context precedes the ones nested in it.
+	contexts []navigationContext
+}
+
+type navigationContext struct {
+	// path is the header of the block, like `foo "bar"`, or the path of YAML keys to the node, like `foo.bar[0]`.
+	path string
+
+	// rng is the range of the node.
+	rng hcl.Range
+
+	// defRange is where the node is defined, that is the key of the node for a value of a mapping,
+	// or the first key of the item for an item of a sequence, as for blocks.
+	defRange hcl.Range
+}
+
+func newNavigation(src *source) *navigation {
+	return &navigation{
+		src: src,
+	}
+}
+
+// addDocument collects the contexts in the YAML document. It is called only while the source is parsed.
+func (n *navigation) addDocument(doc *yaml.Node) {
+	n.addContexts("", documentContent(doc))
+}
+
+// addBlocks collects the top-level blocks of the body according to the navigation schema.
+// It is called only while the source is parsed. Diagnostics are ignored, as they are reported on decoding the body.
+func (n *navigation) addBlocks(body *YamlBody) {
+	if n.src.navigationSchema == nil {
+		return
+	}
+
+	content, _, _ := body.PartialContent(n.src.navigationSchema)
+
+	for _, b := range content.Blocks {
+		buf := &bytes.Buffer{}
+		buf.WriteString(b.Type)
+
+		for _, label := range b.Labels {
+			fmt.Fprintf(buf, " %q", label)
+		}
+
+		n.blocks = append(n.blocks, navigationContext{
+			path:     buf.String(),
+			rng:      hcl.RangeOver(b.DefRange, n.src.nodeRange(b.Body.(*YamlBody).yamlNode)),
+			defRange: b.DefRange,
+		})
+	}
+}
+
+func (n *navigation) addContexts(path string, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+
+			if v.Kind != yaml.MappingNode && v.Kind != yaml.SequenceNode {
+				continue
+			}
+
+			p := k.Value
+			if path != "" {
+				p = path + "." + k.Value
+			}
+
+			n.addContext(p, v, n.src.nodeRange(k))
+		}
+	case yaml.SequenceNode:
+		for i, v := range node.Content {
+			if v.Kind != yaml.MappingNode && v.Kind != yaml.SequenceNode {
+				continue
+			}
+
+			defRange := n.src.nodeRange(v)
+			if v.Kind == yaml.MappingNode && len(v.Content) > 0 {
+				defRange = n.src.nodeRange(v.Content[0])
+			}
+
+			n.addContext(fmt.Sprintf("%s[%d]", path, i), v, defRange)
+		}
+	}
+}
+
+func (n *navigation) addContext(path string, node *yaml.Node, defRange hcl.Range) {
+	n.contexts = append(n.contexts, navigationContext{path: path, rng: n.src.nodeRange(node), defRange: defRange})
+
+	n.addContexts(path, node)
+}
+
+// contextAt returns the top-level block containing the offset, or the innermost context containing the offset.
+func (n *navigation) contextAt(offset int) (navigationContext, bool) {
+	for _, b := range n.blocks {
+		if b.contains(offset) {
+			return b, true
+		}
+	}
+
+	var (
+		found navigationContext
+		ok    bool
+	)
+
+	for _, c := range n.contexts {
+		if c.contains(offset) {
+			found, ok = c, true
+		}
+	}
+
+	return found, ok
+}
+
+// contains returns true if the offset is within the context. Unlike hcl.Range.ContainsOffset, the end of the range
+// is included, as diagnostics for values cut short, like an unterminated function call, point at the end of the value.
+func (c navigationContext) contains(offset int) bool {
+	if c.defRange.ContainsOffset(offset) {
+		return true
+	}
+
+	return offset >= c.rng.Start.Byte && offset <= c.rng.End.Byte
+}
+
+// ContextString returns the header of the top-level block containing the offset, like `foo "bar"`,
+// or the path of YAML keys to the innermost mapping or sequence containing the offset, like `foo.bar[0]`.
+//
+// This is the implementation of hcled.ContextString, which hcl.NewDiagnosticTextWriter uses to print
+// lines like `on example.yaml line 12, in foo "bar":`.
+func (n *navigation) ContextString(offset int) string {
+	if c, ok := n.contextAt(offset); ok {
+		return c.path
+	}
+
+	return ""
+}
+
+// ContextDefRange returns the definition range of the top-level block, or the innermost mapping or sequence
+// containing the offset.
+func (n *navigation) ContextDefRange(offset int) hcl.Range {
+	if c, ok := n.contextAt(offset); ok {
+		return c.defRange
+	}
+
+	return hcl.Range{}
+}
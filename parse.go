@@ -1,60 +1,41 @@
 package hcl2yaml
 
 import (
-	"bytes"
-	"encoding/json"
 	"github.com/hashicorp/hcl/v2"
 	"gopkg.in/yaml.v3"
-	"os"
 )
 
+// Parse parses the YAML source (which is assumed to have been loaded from the given fileName)
+// with the default options, and returns the hcl.File object representing it.
+//
+// Use Parser for more control over how the source is interpreted, or to parse many files and keep track of them.
+//
+// The returned file is immutable, and can be decoded concurrently from many goroutines.
 func Parse(src []byte, fileName string) (*hcl.File, hcl.Diagnostics) {
-	var value yaml.Node
-
-	yamlDecoder := yaml.NewDecoder(bytes.NewReader(src))
-
-	if err := yamlDecoder.Decode(&value); err != nil {
-		return nil, hcl.Diagnostics{
-			&hcl.Diagnostic{
-				Severity:    hcl.DiagError,
-				Summary:     err.Error(),
-				Detail:      "",
-				Subject:     nil,
-				Context:     nil,
-				Expression:  nil,
-				EvalContext: nil,
-			},
-		}
-	}
+	return NewParser().ParseYAML(src, fileName)
+}
 
-	debugEncoder := json.NewEncoder(os.Stdout)
-	debugEncoder.SetIndent("", "  ")
-	if err := debugEncoder.Encode(value); err != nil {
-		return nil, hcl.Diagnostics{
-			&hcl.Diagnostic{
-				Severity:    hcl.DiagError,
-				Summary:     err.Error(),
-				Detail:      "",
-				Subject:     nil,
-				Context:     nil,
-				Expression:  nil,
-				EvalContext: nil,
-			},
-		}
-	}
+// ParseMulti parses a stream of YAML documents separated by `---` with the default options,
+// and returns one hcl.File per document.
+//
+// Use Parser for more control over how the documents are interpreted. See Parser.ParseYAMLMulti for details.
+func ParseMulti(src []byte, fileName string) ([]*hcl.File, hcl.Diagnostics) {
+	return NewParser().ParseYAMLMulti(src, fileName)
+}
 
-	yamlBody := &YamlBody{
-		bytes:    src,
-		fileName: fileName,
-		yamlNode: &value,
-	}
+func newFile(src *source, doc *yaml.Node) *hcl.File {
+	src.scalars.addDocument(src, doc)
+	src.nav.addDocument(doc)
+
+	yamlBody := newYamlBody(src, doc, nil, nil, nil)
+
+	src.nav.addBlocks(yamlBody)
 
 	file := &hcl.File{
 		Body:  yamlBody,
-		Bytes: src,
-		Nav:   nil,
+		Bytes: src.bytes,
+		Nav:   src.nav,
 	}
 
-	return file, nil
+	return file
 }
-
@@ -5,74 +5,98 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
-	"github.com/zclconf/go-cty/cty"
 	"gopkg.in/yaml.v3"
 	"reflect"
-	"strconv"
+	"sort"
 	"strings"
 )
 
+// YamlBody is the hcl.Body of a YAML mapping.
+//
+// A body is immutable once parsed. All the methods of hcl.Body, and evaluating the expressions and decoding
+// the blocks they return, are safe for concurrent use, so a file can be parsed once and decoded from many goroutines
+// with different EvalContexts. Decoding never writes to the state shared by bodies of a file, like the expressions
+// of scalars and the navigation, as they are built once by Parse.
 type YamlBody struct {
-	fileName string
-
-	bytes []byte
+	src *source
 
 	yamlNode *yaml.Node
-}
 
-type yamlBody struct {
-	fileName     string
-	bytes        []byte
-	yamlNode     *yaml.Node
-	attrSchemas  map[string]hcl.AttributeSchema
-	blockSchemas map[string]hcl.BlockHeaderSchema
-}
+	// keyNode is the key of the YAML mapping entry that defined this body as a block, if any.
+	// It is used to report the range of missing items.
+	keyNode *yaml.Node
 
-func (f *YamlBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
-	attrSchemas := map[string]hcl.AttributeSchema{}
+	// ancestors are the YAML nodes enclosing this body, used to detect cyclic aliases.
+	ancestors []*yaml.Node
 
-	for _, a := range schema.Attributes {
-		attrSchemas[a.Name] = a
-	}
+	// If non-nil, the keys of this map cause the corresponding YAML mapping keys to
+	// be treated as non-existing. This is used when PartialContent is
+	// called, to produce the "remaining content" body.
+	hiddenAttrs map[string]struct{}
 
-	blockSchemas := map[string]hcl.BlockHeaderSchema{}
+	// index is the index of the YAML mapping, or nil if the node is not a mapping.
+	index *mappingIndex
+}
 
-	for _, b := range schema.Blocks {
-		blockSchemas[b.Type] = b
+// newYamlBody creates the body of the YAML mapping or document, indexing the mapping.
+func newYamlBody(src *source, node, keyNode *yaml.Node, ancestors []*yaml.Node, hiddenAttrs map[string]struct{}) *YamlBody {
+	return &YamlBody{
+		src:         src,
+		yamlNode:    node,
+		keyNode:     keyNode,
+		ancestors:   ancestors,
+		hiddenAttrs: hiddenAttrs,
+		index:       newMappingIndex(src, node, ancestors),
 	}
+}
 
-	ff := &yamlBody{
-		bytes:        f.bytes,
-		yamlNode:     f.yamlNode,
-		fileName:     f.fileName,
-		attrSchemas:  attrSchemas,
-		blockSchemas: blockSchemas,
-	}
+type yamlBody struct {
+	src         *source
+	yamlNode    *yaml.Node
+	keyNode     *yaml.Node
+	ancestors   []*yaml.Node
+	hiddenAttrs map[string]struct{}
+	index       *mappingIndex
+	schema      *compiledSchema
+
+	// partial is whether keys not in the schema are left to the remaining body, rather than being errors.
+	partial bool
+}
 
-	return ff.content()
+func (f *YamlBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	return f.withSchema(schema, nil).content()
 }
 
-func (f *yamlBody) content() (*hcl.BodyContent, hcl.Diagnostics) {
-	value := f.yamlNode
+func (f *YamlBody) withSchema(schema *hcl.BodySchema, mapSchemaBlocks map[string]Block) *yamlBody {
+	return &yamlBody{
+		src:         f.src,
+		yamlNode:    f.yamlNode,
+		keyNode:     f.keyNode,
+		ancestors:   f.ancestors,
+		hiddenAttrs: f.hiddenAttrs,
+		index:       f.index,
+		schema:      f.src.schemas.get(f.src, schema, mapSchemaBlocks),
+	}
+}
 
-	// 4
-	if value.Kind == yaml.MappingNode {
-		return f.parseMapping(value)
+func (f *yamlBody) content() (*hcl.BodyContent, hcl.Diagnostics) {
+	if f.index != nil {
+		return f.parseMapping()
 	}
 
-	// 1
+	value := f.yamlNode
 	if value.Kind == yaml.DocumentNode {
-		return f.parseMapping((value.Content[0]))
+		value = documentContent(value)
 	}
 
 	err := fmt.Errorf("unexpected yaml node kind: expected DocumentNode(1) or MappingNode(4), got %v", value.Kind)
 
-	return nil, hcl.Diagnostics{
+	return &hcl.BodyContent{MissingItemRange: f.src.nodeRange(value)}, hcl.Diagnostics{
 		&hcl.Diagnostic{
 			Severity:    hcl.DiagError,
 			Summary:     err.Error(),
 			Detail:      "",
-			Subject:     nil,
+			Subject:     f.src.nodeRange(value).Ptr(),
 			Context:     nil,
 			Expression:  nil,
 			EvalContext: nil,
@@ -81,248 +105,386 @@ func (f *yamlBody) content() (*hcl.BodyContent, hcl.Diagnostics) {
 }
 
 func (f *YamlBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
-	panic("implement me")
+	ff := f.withSchema(schema, nil)
+	ff.partial = true
+
+	content, diags := ff.content()
+
+	usedNames := map[string]struct{}{}
+
+	for k := range f.hiddenAttrs {
+		usedNames[k] = struct{}{}
+	}
+
+	for _, a := range schema.Attributes {
+		usedNames[a.Name] = struct{}{}
+	}
+
+	for k := range ff.schema.blockTypes {
+		usedNames[k] = struct{}{}
+	}
+
+	remain := &YamlBody{
+		src:         f.src,
+		yamlNode:    f.yamlNode,
+		keyNode:     f.keyNode,
+		ancestors:   f.ancestors,
+		hiddenAttrs: usedNames,
+		index:       f.index,
+	}
+
+	return content, remain, diags
 }
 
+// JustAttributes interprets all keys of the wrapped YAML mapping as attributes and returns them.
 func (f *YamlBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
-	panic("implement me")
+	ff := &yamlBody{
+		src:         f.src,
+		yamlNode:    f.yamlNode,
+		keyNode:     f.keyNode,
+		ancestors:   f.ancestors,
+		hiddenAttrs: f.hiddenAttrs,
+		index:       f.index,
+	}
+
+	return ff.justAttributes()
 }
 
+func (f *yamlBody) justAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	if f.index == nil {
+		node := f.yamlNode
+		if node.Kind == yaml.DocumentNode {
+			node = documentContent(node)
+		}
+
+		err := fmt.Errorf("unexpected yaml node kind: expected DocumentNode(1) or MappingNode(4), got %v", node.Kind)
+
+		return hcl.Attributes{}, hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  err.Error(),
+				Detail:   "A YAML mapping is required here, setting the arguments for this block.",
+				Subject:  f.src.nodeRange(node).Ptr(),
+			},
+		}
+	}
+
+	node := f.index.node
+
+	attrs := hcl.Attributes{}
+
+	diags := append(hcl.Diagnostics(nil), f.index.diags...)
+
+	parents := appendNode(f.ancestors, node)
+
+	for _, e := range f.index.entries {
+		keyNode := e.key
+
+		if keyNode.Kind != yaml.ScalarNode {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Unexpected key kind. Expected ScalarNode(8), got %v", keyNode.Kind),
+				Subject:  f.src.nodeRange(keyNode).Ptr(),
+				Context:  f.src.nodeRange(node).Ptr(),
+			})
+
+			continue
+		}
+
+		k := keyNode.Value
+
+		if _, hidden := f.hiddenAttrs[k]; hidden {
+			continue
+		}
+
+		attr, attrDiags := f.parseAttrsFromYaml(keyNode, e.value, e.alias, parents)
+		diags = append(diags, withAliasContext(f.src, attrDiags, e.alias)...)
+
+		if attr != nil {
+			attrs[k] = attr
+		}
+	}
+
+	return attrs, diags
+}
+
+// MissingItemRange returns the range of the key that defined this body as a block,
+// or the range of the whole YAML mapping for the top-level body.
 func (f *YamlBody) MissingItemRange() hcl.Range {
-	panic("implement me")
+	return missingItemRange(f.src, f.keyNode, f.yamlNode)
+}
+
+func missingItemRange(src *source, keyNode, valNode *yaml.Node) hcl.Range {
+	if keyNode != nil {
+		return src.nodeRange(keyNode)
+	}
+
+	return src.nodeRange(valNode)
 }
 
 var _ hcl.Body = &YamlBody{}
 
-func (f *yamlBody) parseMapping(node *yaml.Node) (*hcl.BodyContent, hcl.Diagnostics) {
-	var bodyContent hcl.BodyContent
+// parseMapping returns the content of the mapping according to the schema.
+//
+// Like hclsyntax, it always returns the content, containing the attributes and blocks parsed successfully
+// even when some of the others failed, along with the diagnostics for all of the failures.
+func (f *yamlBody) parseMapping() (*hcl.BodyContent, hcl.Diagnostics) {
+	node := f.index.node
 
-	keyToValue := map[string]*yaml.Node{}
+	bodyContent := &hcl.BodyContent{
+		Attributes:       hcl.Attributes{},
+		MissingItemRange: missingItemRange(f.src, f.keyNode, node),
+	}
 
-	for i := 0; i < len(node.Content); i += 2 {
-		keyNode := node.Content[i]
-		valueNode := node.Content[i+1]
+	diags := append(hcl.Diagnostics(nil), f.index.diags...)
 
-		if keyNode.Kind != yaml.ScalarNode {
-			return nil, hcl.Diagnostics{
-				&hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  fmt.Sprintf("Unexpected key kind. Expected ScalarNode(8), got %v", keyNode.Kind),
-					Detail:   "",
-					Subject: hcl.Range{
-						Filename: f.fileName,
-						Start: hcl.Pos{
-							Line:   keyNode.Line,
-							Column: keyNode.Column,
-						},
-						End: hcl.Pos{
-							Line:   keyNode.Line,
-							Column: keyNode.Column + len(keyNode.Value),
-						},
-					}.Ptr(),
-					Context: hcl.Range{
-						Filename: f.fileName,
-						Start: hcl.Pos{
-							Line:   keyNode.Line - 1,
-							Column: 0,
-						},
-						End: hcl.Pos{
-							Line:   keyNode.Line + 1,
-							Column: 0,
-						},
-					}.Ptr(),
-					Expression:  nil,
-					EvalContext: nil,
-				},
-			}
+	for _, e := range f.index.entries {
+		if e.key.Kind != yaml.ScalarNode {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     fmt.Sprintf("Unexpected key kind. Expected ScalarNode(8), got %v", e.key.Kind),
+				Detail:      "",
+				Subject:     f.src.nodeRange(e.key).Ptr(),
+				Context:     f.src.nodeRange(node).Ptr(),
+				Expression:  nil,
+				EvalContext: nil,
+			})
 		}
+	}
 
-		k := keyNode.Value
+	parents := appendNode(f.ancestors, node)
 
-		vs := valueNode
+	var missingAttrs []string
 
-		keyToValue[k] = vs
+	for k, attrSchema := range f.schema.attrs {
+		if _, exists := f.entry(k); attrSchema.Required && !exists {
+			missingAttrs = append(missingAttrs, k)
+		}
 	}
 
-	attrs := map[string]*hcl.Attribute{}
+	sort.Strings(missingAttrs)
 
-	for k, attrSchema := range f.attrSchemas {
-		c, exists := keyToValue[k]
-		if attrSchema.Required && !exists {
-			return nil, hcl.Diagnostics{
-				&hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  fmt.Sprintf("no yaml mapping found for required attribute %q", k),
-				},
+	for _, k := range missingAttrs {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("no yaml mapping found for required attribute %q", k),
+			Subject:  missingItemRange(f.src, f.keyNode, node).Ptr(),
+		})
+	}
+
+	// Keys are processed in the source order, so that blocks are produced in the order they are defined.
+	for _, e := range f.index.entries {
+		if e.key.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		key := e.key.Value
+
+		if _, hidden := f.hiddenAttrs[key]; hidden {
+			continue
+		}
+
+		c := e.value
+
+		if _, isAttr := f.schema.attrs[key]; isAttr {
+			attr, attrDiags := f.parseAttrsFromYaml(e.key, c, e.alias, parents)
+			diags = append(diags, withAliasContext(f.src, attrDiags, e.alias)...)
+
+			if attr != nil {
+				bodyContent.Attributes[key] = attr
 			}
+
+			continue
 		}
 
-		attr, diags := f.parseAttrsFromYaml(k, c)
-		if diags.HasErrors() {
-			return nil, diags
+		k, isBlock := f.schema.blockTypes[key]
+		if !isBlock {
+			continue
 		}
 
-		attrs[k] = attr
-	}
+		blockSchema := f.schema.blocks[k]
 
-	var blocks []*hcl.Block
+		if key != k {
+			if _, singularExists := f.entry(k); singularExists {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Both %q and %q are defined", k, key),
+					Detail:   fmt.Sprintf("Blocks of the type %q can be defined either as a mapping under the key %q or as a sequence of mappings under the key %q, but not both.", k, k, key),
+					Subject:  f.src.nodeRange(e.key).Ptr(),
+					Context:  f.src.nodeRange(node).Ptr(),
+				})
 
-	for k, blockSchema := range f.blockSchemas {
-		c, exists := keyToValue[k]
-		if !exists {
-			// TODO Pluralization support
-			c, exists = keyToValue[k]
-			if !exists {
-				return nil, hcl.Diagnostics{
-					&hcl.Diagnostic{
-						Severity: hcl.DiagError,
-						Summary:  fmt.Sprintf("no yaml mapping found for expected block %q", k),
-					},
-				}
+				continue
 			}
 		}
 
 		switch c.Kind {
 		case yaml.SequenceNode:
-			bls, diags := f.parseBlocksFromYamlSequence(k, blockSchema, c)
-			if diags.HasErrors() {
-				return nil, diags
-			}
+			bls, blockDiags := f.parseBlocksFromYamlSequence(k, blockSchema, e.key, c, parents)
+			diags = append(diags, withAliasContext(f.src, blockDiags, e.alias)...)
 
-			blocks = append(blocks, bls...)
+			bodyContent.Blocks = append(bodyContent.Blocks, bls...)
 		case yaml.MappingNode:
-			bl, diags := f.parseBlockFromYamlMapping(k, blockSchema, c)
-			if diags.HasErrors() {
-				return nil, diags
-			}
+			bls, blockDiags := f.parseBlocksFromYamlMapping(k, blockSchema, e.key, c, parents, f.src.nodeRange(e.key))
+			diags = append(diags, withAliasContext(f.src, blockDiags, e.alias)...)
 
-			blocks = append(blocks, bl)
+			bodyContent.Blocks = append(bodyContent.Blocks, bls...)
 		default:
-			return nil, hcl.Diagnostics{
-				&hcl.Diagnostic{
-					Severity:    hcl.DiagError,
-					Summary:     fmt.Sprintf("unsupported type of yaml node: %v", c.Kind),
-					Detail:      "",
-					Subject:     nil,
-					Context:     nil,
-					Expression:  nil,
-					EvalContext: nil,
-				},
-			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     fmt.Sprintf("unsupported type of yaml node: %v", c.Kind),
+				Detail:      "",
+				Subject:     f.src.nodeRange(c).Ptr(),
+				Context:     nil,
+				Expression:  nil,
+				EvalContext: nil,
+			})
 		}
 	}
 
-	bodyContent.Attributes = attrs
-	bodyContent.Blocks = blocks
+	if !f.partial {
+		diags = append(diags, f.unsupportedKeys()...)
+	}
 
-	return &bodyContent, nil
+	return bodyContent, diags
 }
 
-func (f *yamlBody) parseAttrsFromYaml(name string, valNode *yaml.Node) (*hcl.Attribute, hcl.Diagnostics) {
-	switch valNode.Kind {
-	case yaml.MappingNode:
-		var expr hcl.Expression
+// entry returns the entry of the key in the mapping, unless the key is hidden.
+func (f *yamlBody) entry(key string) (mappingEntry, bool) {
+	if _, hidden := f.hiddenAttrs[key]; hidden {
+		return mappingEntry{}, false
+	}
+
+	return f.index.get(key)
+}
+
+// unsupportedKeys reports the keys that are neither attributes nor blocks in the schema, like native HCL does.
+//
+// A key is reported as an unsupported block type when its value looks like a block, that is a mapping
+// or a sequence of mappings, and as an unsupported argument otherwise.
+func (f *yamlBody) unsupportedKeys() hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	for _, e := range f.index.entries {
+		k := e.key.Value
+
+		if _, hidden := f.hiddenAttrs[k]; hidden {
+			continue
+		}
+
+		if _, ok := f.schema.attrs[k]; ok {
+			continue
+		}
 
-		expr = &MappingExpression{f: f, Node: valNode}
+		if _, ok := f.schema.blockTypes[k]; ok {
+			continue
+		}
 
-		attr := &hcl.Attribute{
-			Name:      name,
-			Expr:      expr,
-			Range:     expr.Range(),
-			NameRange: hcl.Range{},
+		var suggestion string
+		if s := nameSuggestion(k, f.schema.names()); s != "" {
+			suggestion = fmt.Sprintf(" Did you mean %q?", s)
 		}
 
-		return attr, nil
+		if looksLikeBlock(e.value) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported block type",
+				Detail:   fmt.Sprintf("Blocks of type %q are not expected here.%s", k, suggestion),
+				Subject:  f.src.nodeRange(e.key).Ptr(),
+			})
+		} else {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported argument",
+				Detail:   fmt.Sprintf("An argument named %q is not expected here.%s", k, suggestion),
+				Subject:  f.src.nodeRange(e.key).Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
+
+// looksLikeBlock returns true if the node is a mapping or a non-empty sequence of mappings.
+func looksLikeBlock(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return true
 	case yaml.SequenceNode:
-		var expr hcl.Expression
-
-		expr = &SequenceExpression{f: f, Node: valNode}
-
-		attr := &hcl.Attribute{
-			Name:      name,
-			Expr:      expr,
-			Range:     expr.Range(),
-			NameRange: hcl.Range{},
-		}
-
-		return attr, nil
-		//
-		//var vs []cty.Value
-		//
-		//for _, v := range valNode.Content {
-		//	switch v.Kind {
-		//	case yaml.ScalarNode:
-		//		vs = append(vs, cty.StringVal(v.Value))
-		//	}
-		//}
-		//
-		//lastElem := valNode.Content[len(valNode.Content)-1]
-		//
-		//rng := hcl.Range{
-		//	Filename: f.fileName,
-		//	Start: hcl.Pos{
-		//		Column: valNode.Column,
-		//		Line:   valNode.Line,
-		//	},
-		//	End: hcl.Pos{
-		//		Column: lastElem.Column + len(lastElem.Value),
-		//		Line:   lastElem.Line,
-		//	},
-		//}
-		//
-		//var expr hcl.Expression
-		//
-		//if len(vs) > 0 {
-		//	expr = hcl.StaticExpr(cty.ListVal(vs), rng)
-		//} else {
-		//	expr = hcl.StaticExpr(cty.DynamicVal, rng)
-		//}
-		//
-		//attr := &hcl.Attribute{
-		//	Name:      name,
-		//	Expr:      expr,
-		//	Range:     rng,
-		//	NameRange: hcl.Range{},
-		//}
-		//
-		//return attr, nil
+		if len(n.Content) == 0 {
+			return false
+		}
+
+		for _, item := range n.Content {
+			if item.Kind != yaml.MappingNode && (item.Kind != yaml.AliasNode || item.Alias.Kind != yaml.MappingNode) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// parseAttrsFromYaml parses the YAML node into an attribute named after the key.
+//
+// alias is the alias node the value has been resolved from, if any. The range of the attribute spans
+// from the key to the alias rather than to the anchored value, which can be anywhere in the document.
+func (f *yamlBody) parseAttrsFromYaml(keyNode, valNode, alias *yaml.Node, ancestors []*yaml.Node) (*hcl.Attribute, hcl.Diagnostics) {
+	name := keyNode.Value
+
+	var expr hcl.Expression
+
+	var diags hcl.Diagnostics
+
+	switch valNode.Kind {
+	case yaml.MappingNode:
+		expr, diags = newMappingExpression(f, valNode, ancestors)
+	case yaml.SequenceNode:
+		expr, diags = newSequenceExpression(f, valNode, ancestors)
 	case yaml.ScalarNode:
-		expr, diags := f.ParseScalar(valNode)
+		expr, diags = f.ParseScalar(valNode)
 		if diags.HasErrors() {
 			return nil, diags
 		}
-
-		attr := &hcl.Attribute{
-			Name:      name,
-			Expr:      expr,
-			Range:     expr.Range(),
-			NameRange: hcl.Range{},
+	default:
+		return nil, hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     fmt.Sprintf("unable to parse attribute of unsupported kind/tag %q: %v %s", name, valNode.Kind, valNode.Tag),
+				Detail:      "",
+				Subject:     f.src.nodeRange(valNode).Ptr(),
+				Context:     nil,
+				Expression:  nil,
+				EvalContext: nil,
+			},
 		}
+	}
 
-		return attr, nil
+	end := valNode
+	if alias != nil {
+		end = alias
 	}
 
-	return nil, hcl.Diagnostics{
-		&hcl.Diagnostic{
-			Severity:    hcl.DiagError,
-			Summary:     fmt.Sprintf("unable to parse attribute of unsupported kind/tag %q: %v %s", name, valNode.Kind, valNode.Tag),
-			Detail:      "",
-			Subject:     nil,
-			Context:     nil,
-			Expression:  nil,
-			EvalContext: nil,
-		},
+	attr := &hcl.Attribute{
+		Name:      name,
+		Expr:      expr,
+		Range:     f.src.rangeBetween(f.src.nodeStart(keyNode), f.src.nodeEnd(end)),
+		NameRange: f.src.nodeRange(keyNode),
 	}
+
+	return attr, diags
 }
 
-func (f *yamlBody) parseBlocksFromYamlSequence(tpe string, blockSchema hcl.BlockHeaderSchema, valNode *yaml.Node) ([]*hcl.Block, hcl.Diagnostics) {
+func (f *yamlBody) parseBlocksFromYamlSequence(tpe string, blockSchema hcl.BlockHeaderSchema, keyNode, valNode *yaml.Node, ancestors []*yaml.Node) ([]*hcl.Block, hcl.Diagnostics) {
 	if valNode.Kind != yaml.SequenceNode {
 		return nil, hcl.Diagnostics{
 			&hcl.Diagnostic{
 				Severity:    hcl.DiagError,
-				Summary:     fmt.Sprintf("unsupported type of node for blocks %q. It must be SequenceNode, but got ", valNode.Kind),
+				Summary:     fmt.Sprintf("unsupported type of node for blocks %q. It must be SequenceNode, but got %v", tpe, valNode.Kind),
 				Detail:      "",
-				Subject:     nil,
+				Subject:     f.src.nodeRange(valNode).Ptr(),
 				Context:     nil,
 				Expression:  nil,
 				EvalContext: nil,
@@ -330,92 +492,267 @@ func (f *yamlBody) parseBlocksFromYamlSequence(tpe string, blockSchema hcl.Block
 		}
 	}
 
-	var bls []*hcl.Block
+	var (
+		bls   []*hcl.Block
+		diags hcl.Diagnostics
+	)
+
+	parents := appendNode(ancestors, valNode)
+
+	for _, item := range valNode.Content {
+		n, resolveDiags := resolveNode(f.src, item, parents)
+		if resolveDiags.HasErrors() {
+			diags = append(diags, resolveDiags...)
+
+			continue
+		}
 
-	for _, n := range valNode.Content {
 		switch n.Kind {
 		case yaml.MappingNode:
+			bl, blockDiags := f.parseBlocksFromYamlMapping(tpe, blockSchema, keyNode, n, parents, f.src.firstKeyRange(n))
+			diags = append(diags, withAliasContext(f.src, blockDiags, item)...)
 
-			bl, diags := f.parseBlockFromYamlMapping(tpe, blockSchema, n)
-			if diags.HasErrors() {
-				return nil, diags
-			}
-
-			bls = append(bls, bl)
+			bls = append(bls, bl...)
 
 		default:
-			return nil, hcl.Diagnostics{
-				&hcl.Diagnostic{
-					Severity:    hcl.DiagError,
-					Summary:     fmt.Sprintf("unsupported type of value node for blocks %q. It must be MappingNode", n.Kind),
-					Detail:      "",
-					Subject:     nil,
-					Context:     nil,
-					Expression:  nil,
-					EvalContext: nil,
-				},
+			diags = append(diags, &hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     fmt.Sprintf("unsupported type of value node for blocks %q. It must be MappingNode, but got %v", tpe, n.Kind),
+				Detail:      "",
+				Subject:     f.src.nodeRange(n).Ptr(),
+				Context:     nil,
+				Expression:  nil,
+				EvalContext: nil,
+			})
+		}
+	}
+
+	return bls, diags
+}
+
+// parseBlocksFromYamlMapping parses the YAML mapping into blocks, reading labels in the label style of the block type.
+//
+// A mapping results in exactly one block in the field style, and zero or more blocks in the map style.
+func (f *yamlBody) parseBlocksFromYamlMapping(tpe string, blockSchema hcl.BlockHeaderSchema, keyNode, valNode *yaml.Node, ancestors []*yaml.Node, defRange hcl.Range) ([]*hcl.Block, hcl.Diagnostics) {
+	index := newMappingIndex(f.src, valNode, ancestors)
+
+	if f.labelStyle(tpe, blockSchema, index) == LabelStyleMap {
+		return f.parseLabeledBlocks(tpe, blockSchema, keyNode, nil, valNode, ancestors)
+	}
+
+	bl, diags := f.parseBlockFromYamlMapping(tpe, blockSchema, keyNode, index, ancestors, defRange)
+	if bl == nil {
+		return nil, diags
+	}
+
+	return []*hcl.Block{bl}, diags
+}
+
+// parseLabeledBlocks parses the YAML node into blocks whose labels are the keys of nested mappings.
+//
+// labelKeys are the key nodes of the labels read so far. The node is the mapping of the next label
+// until all the labels are read, and then the block body, or a sequence of block bodies sharing the labels.
+func (f *yamlBody) parseLabeledBlocks(tpe string, blockSchema hcl.BlockHeaderSchema, typeKey *yaml.Node, labelKeys []*yaml.Node, node *yaml.Node, ancestors []*yaml.Node) ([]*hcl.Block, hcl.Diagnostics) {
+	if len(labelKeys) == len(blockSchema.LabelNames) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			return []*hcl.Block{f.newLabeledBlock(tpe, typeKey, labelKeys, node, ancestors)}, nil
+		case yaml.SequenceNode:
+			var (
+				blocks []*hcl.Block
+				diags  hcl.Diagnostics
+			)
+
+			parents := appendNode(ancestors, node)
+
+			for _, item := range node.Content {
+				n, resolveDiags := resolveNode(f.src, item, parents)
+				if resolveDiags.HasErrors() {
+					diags = append(diags, resolveDiags...)
+
+					continue
+				}
+
+				if n.Kind != yaml.MappingNode {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Invalid block body",
+						Detail:   fmt.Sprintf("The body of a %s block must be a mapping, but got a %s.", tpe, kindName(n.Kind)),
+						Subject:  f.src.nodeRange(item).Ptr(),
+					})
+
+					continue
+				}
+
+				blocks = append(blocks, f.newLabeledBlock(tpe, typeKey, labelKeys, n, parents))
 			}
+
+			return blocks, diags
+		}
+
+		return nil, hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid block body",
+				Detail: fmt.Sprintf(
+					"The body of a %s block must be a mapping or a sequence of mappings, but got a %s. A %s block has %d label(s): %s, each of which is a key of the nested mappings.",
+					tpe, kindName(node.Kind), tpe, len(blockSchema.LabelNames), strings.Join(blockSchema.LabelNames, ", "),
+				),
+				Subject: f.src.nodeRange(node).Ptr(),
+			},
 		}
 	}
 
-	return bls, nil
+	labelName := blockSchema.LabelNames[len(labelKeys)]
+
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return nil, hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing block label",
+				Detail: fmt.Sprintf(
+					"A %s block requires %d label(s): %s. A mapping with at least one key is required here, whose keys represent the %s block's %s.",
+					tpe, len(blockSchema.LabelNames), strings.Join(blockSchema.LabelNames, ", "), tpe, labelName,
+				),
+				Subject: f.src.nodeRange(node).Ptr(),
+			},
+		}
+	}
+
+	entries, diags := mappingEntries(f.src, node, ancestors)
+
+	parents := appendNode(ancestors, node)
+
+	var blocks []*hcl.Block
+
+	for _, e := range entries {
+		if e.key.Kind != yaml.ScalarNode {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid block label",
+				Detail:   fmt.Sprintf("The %s of a %s block must be a scalar key, but got a %s.", labelName, tpe, kindName(e.key.Kind)),
+				Subject:  f.src.nodeRange(e.key).Ptr(),
+			})
+
+			continue
+		}
+
+		// Like labels in fields, expressions(!!exp) and non-string scalars are not allowed.
+		if e.key.Tag != "!!str" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid block label",
+				Detail:   fmt.Sprintf("The %s of a %s block must be a plain string scalar, but got a node tagged %s.", labelName, tpe, e.key.Tag),
+				Subject:  f.src.nodeRange(e.key).Ptr(),
+			})
+
+			continue
+		}
+
+		bls, labelDiags := f.parseLabeledBlocks(tpe, blockSchema, typeKey, appendNode(labelKeys, e.key), e.value, parents)
+		diags = append(diags, withAliasContext(f.src, labelDiags, e.alias)...)
+
+		blocks = append(blocks, bls...)
+	}
+
+	return blocks, diags
 }
 
-func (f *yamlBody) parseBlockFromYamlMapping(tpe string, blockSchema hcl.BlockHeaderSchema, valNode *yaml.Node) (*hcl.Block, hcl.Diagnostics) {
+func (f *yamlBody) newLabeledBlock(tpe string, typeKey *yaml.Node, labelKeys []*yaml.Node, body *yaml.Node, ancestors []*yaml.Node) *hcl.Block {
+	block := &hcl.Block{
+		Type:      tpe,
+		TypeRange: f.src.nodeRange(typeKey),
+		// Like HCL's JSON syntax, the block is defined where its body begins, that is the key of the last label.
+		DefRange: f.src.nodeRange(labelKeys[len(labelKeys)-1]),
+	}
+
+	for _, k := range labelKeys {
+		block.Labels = append(block.Labels, k.Value)
+		block.LabelRanges = append(block.LabelRanges, f.src.nodeRange(k))
+	}
+
+	block.Body = newYamlBody(f.src, body, labelKeys[len(labelKeys)-1], ancestors, nil)
+
+	return block
+}
+
+// parseBlockFromYamlMapping parses the YAML mapping into a block whose labels are read from the fields named after
+// the label names. The block body is a view of the mapping excluding the label fields.
+//
+// defRange is where the block is defined, that is the key of the block type for a mapping, or the first key of
+// the item for an item of a sequence.
+func (f *yamlBody) parseBlockFromYamlMapping(tpe string, blockSchema hcl.BlockHeaderSchema, keyNode *yaml.Node, index *mappingIndex, ancestors []*yaml.Node, defRange hcl.Range) (*hcl.Block, hcl.Diagnostics) {
 	var block hcl.Block
 
 	block.Type = tpe
+	block.TypeRange = f.src.nodeRange(keyNode)
+	block.DefRange = defRange
 
-	m := mappingKVs(valNode)
+	if index.diags.HasErrors() {
+		return nil, sharedDiags(index.diags)
+	}
+
+	valNode := index.node
 
 	for _, label := range blockSchema.LabelNames {
-		labelVal, exists := m[label]
+		labelEntry, exists := index.get(label)
 		if !exists {
 			var ks []string
-			for k := range m {
-				ks = append(ks, k)
+			for _, e := range index.entries {
+				ks = append(ks, e.key.Value)
 			}
 
 			return nil, hcl.Diagnostics{
 				&hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  fmt.Sprintf("Value for label %q not found in %v: %v", label, strings.Join(ks, ", "), valNode),
-					Detail:   "",
-					Subject: hcl.Range{
-						Filename: f.fileName,
-						Start: hcl.Pos{
-							Line:   valNode.Line,
-							Column: valNode.Column,
-						},
-						End: hcl.Pos{
-							Line:   valNode.Line,
-							Column: valNode.Column,
-						},
-					}.Ptr(),
-					Context: hcl.Range{
-						Filename: f.fileName,
-						Start: hcl.Pos{
-							Line:   valNode.Line - 1,
-							Column: 0,
-						},
-						End: hcl.Pos{
-							Line:   valNode.Line + 1,
-							Column: 0,
-						},
-					}.Ptr(),
+					Severity:    hcl.DiagError,
+					Summary:     fmt.Sprintf("Value for label %q not found in %v: %v", label, strings.Join(ks, ", "), valNode),
+					Detail:      "",
+					Subject:     f.src.nodeStartRange(valNode).Ptr(),
+					Context:     f.src.nodeRange(valNode).Ptr(),
 					Expression:  nil,
 					EvalContext: nil,
 				},
 			}
 		}
 
+		labelVal := labelEntry.value
+
+		labelRange := f.src.nodeRange(labelVal)
+		if labelEntry.alias != nil {
+			labelRange = f.src.nodeRange(labelEntry.alias)
+		}
+
+		// Labels are static strings in HCL, so expressions(!!exp) and non-string scalars are not allowed.
+		if labelVal.Kind != yaml.ScalarNode || labelVal.Tag != "!!str" {
+			return nil, hcl.Diagnostics{
+				&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid block label",
+					Detail:   fmt.Sprintf("The %s of a %s block must be a plain string scalar, but got a node tagged %s.", label, tpe, labelVal.Tag),
+					Subject:  labelRange.Ptr(),
+					Context:  f.src.nodeRange(valNode).Ptr(),
+				},
+			}
+		}
+
 		block.Labels = append(block.Labels, labelVal.Value)
+		block.LabelRanges = append(block.LabelRanges, labelRange)
+	}
+
+	// Label keys are hidden so that the block body doesn't report them as unsupported arguments.
+	hiddenAttrs := map[string]struct{}{}
+
+	for _, label := range blockSchema.LabelNames {
+		hiddenAttrs[label] = struct{}{}
 	}
 
 	ff := &YamlBody{
-		bytes: f.bytes,
-		fileName: f.fileName,
-		yamlNode: valNode,
+		src:         f.src,
+		yamlNode:    valNode,
+		keyNode:     keyNode,
+		ancestors:   ancestors,
+		hiddenAttrs: hiddenAttrs,
+		index:       index,
 	}
 
 	block.Body = ff
@@ -423,34 +760,34 @@ func (f *yamlBody) parseBlockFromYamlMapping(tpe string, blockSchema hcl.BlockHe
 	return &block, nil
 }
 
+// ParseScalar parses the scalar into an expression according to its tag.
+// The expressions of scalar values are parsed once by Parse and shared across calls.
 func (f *yamlBody) ParseScalar(valNode *yaml.Node) (hcl.Expression, hcl.Diagnostics) {
+	if expr, ok := f.src.scalars[valNode]; ok {
+		return expr, nil
+	}
+
+	return f.parseScalar(valNode)
+}
+
+func (f *yamlBody) parseScalar(valNode *yaml.Node) (hcl.Expression, hcl.Diagnostics) {
 	switch valNode.Tag {
 	case "!!exp":
 		return f.ParseExpression(valNode)
 	case "!!str":
 		return f.ParseTemplate(valNode)
-	case "!!int":
-		v := valNode.Value
-
-		rng := hcl.Range{
-			Filename: f.fileName,
-			Start: hcl.Pos{
-				Column: valNode.Column,
-				Line:   valNode.Line,
-			},
-			End: hcl.Pos{
-				Column: valNode.Column + len(v),
-				Line:   valNode.Line,
-			},
-		}
+	}
+
+	rng := f.src.nodeRange(valNode)
 
-		intval, err := strconv.Atoi(v)
+	if parse, ok := f.src.tags[valNode.Tag]; ok {
+		val, err := parse(valNode.Value)
 		if err != nil {
 			return nil, hcl.Diagnostics{
 				&hcl.Diagnostic{
 					Severity:    hcl.DiagError,
-					Summary:     err.Error(),
-					Detail:      "",
+					Summary:     fmt.Sprintf("unable to parse yaml node of tag %s", valNode.Tag),
+					Detail:      err.Error(),
 					Subject:     &rng,
 					Context:     nil,
 					Expression:  nil,
@@ -459,19 +796,11 @@ func (f *yamlBody) ParseScalar(valNode *yaml.Node) (hcl.Expression, hcl.Diagnost
 			}
 		}
 
-		return hcl.StaticExpr(cty.NumberIntVal(int64(intval)), rng), nil
+		return hcl.StaticExpr(val, rng), nil
 	}
 
-	rng := hcl.Range{
-		Filename: f.fileName,
-		Start: hcl.Pos{
-			Column: valNode.Column,
-			Line:   valNode.Line,
-		},
-		End: hcl.Pos{
-			Column: valNode.Column + len(valNode.Value),
-			Line:   valNode.Line,
-		},
+	if !f.src.strict {
+		return f.ParseTemplate(valNode)
 	}
 
 	return nil, hcl.Diagnostics{
@@ -487,38 +816,60 @@ func (f *yamlBody) ParseScalar(valNode *yaml.Node) (hcl.Expression, hcl.Diagnost
 	}
 }
 
+// ParseExpression parses the scalar as a HCL native syntax expression.
+//
+// The expression is parsed from the scalar value rather than the source, as yaml.v3 strips the indentation and
+// the line breaks of block scalars, which are significant in heredocs. Positions in the returned expression
+// and diagnostics are mapped back to the YAML source, so that the diagnostic writer is able to point
+// at the failing sub-expression even on the later lines of block scalars.
 func (f *yamlBody) ParseExpression(valNode *yaml.Node) (hcl.Expression, hcl.Diagnostics) {
-	//start := hcl.Pos{
-	//	Column: valNode.Column + len(valNode.Tag),
-	//	Line:   valNode.Line,
-	//}
-	//
-	//return hclsyntax.ParseExpression(f.bytes, f.fileName, start)
-
-	return hclsyntax.ParseExpression([]byte(valNode.Value), "", hcl.Pos{})
+	offsets := f.src.scalarOffsets(valNode)
+
+	expr, diags := hclsyntax.ParseExpression([]byte(valNode.Value), f.src.fileName, offsets.start)
+
+	offsets.remap(expr, diags)
+
+	return expr, diags
 }
 
+// ParseTemplate parses the scalar as a HCL native syntax template.
+//
+// Unlike expressions, whitespaces are significant in templates, so the template is parsed from the
+// scalar value, and positions are mapped back to the YAML source like ParseExpression does.
+// Positions are accurate unless the scalar contains escape sequences.
+//
+// Like HCL native syntax, a template consisting of a single interpolation like "${var.replicas}" evaluates to
+// the interpolated value as-is, so that numbers, lists and objects keep their types rather than becoming strings.
+//
+// The template is wrapped so that the string can also be interpreted as a static traversal.
 func (f *yamlBody) ParseTemplate(valNode *yaml.Node) (hcl.Expression, hcl.Diagnostics) {
-	//start := hcl.Pos{
-	//	Column: valNode.Column,
-	//	Line:   valNode.Line,
-	//}
+	offsets := f.src.scalarOffsets(valNode)
+
+	expr, diags := hclsyntax.ParseTemplate([]byte(valNode.Value), f.src.fileName, offsets.start)
+
+	offsets.remap(expr, diags)
 
-	//return hclsyntax.ParseTemplate(f.bytes, f.fileName, start)
+	if diags.HasErrors() {
+		return expr, diags
+	}
 
-	return hclsyntax.ParseTemplate([]byte(valNode.Value), "", hcl.Pos{})
+	return newStringExpression(f.src, valNode, expr), diags
 }
 
 func parseBlocksIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent, blockToMapSchema map[string]Block, dest map[string]interface{}) hcl.Diagnostics {
 	blocksByType := bodyContent.Blocks.ByType()
 
-	for tpe, blockSchema := range blockToMapSchema {
-		blocks, ok := blocksByType[tpe]
-		if !ok {
-			blocks, ok = blocksByType[blockSchema.Plural]
-			if !ok {
-				continue
-			}
+	for _, tpe := range sortedBlockTypes(blockToMapSchema) {
+		blockSchema := blockToMapSchema[tpe]
+
+		blocks := blocksByType[tpe]
+
+		if diags := validateBlockCount(tpe, blockSchema, blocks, bodyContent.MissingItemRange); diags.HasErrors() {
+			return diags
+		}
+
+		if len(blocks) == 0 {
+			continue
 		}
 
 		delete(blocksByType, tpe)
@@ -528,7 +879,7 @@ func parseBlocksIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent, bloc
 		bodySchema := blockSchema.BodySchema()
 
 		for _, b := range blocks {
-			blockBodyContent, diags := b.Body.Content(bodySchema)
+			blockBodyContent, diags := bodyContentOf(b.Body, bodySchema, blockSchema.Blocks)
 
 			if diags.HasErrors() {
 				return diags
@@ -551,28 +902,70 @@ func parseBlocksIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent, bloc
 			r = append(r, m)
 		}
 
-		if blockSchema.Singleton && len(r) > 0 {
+		if blockSchema.Plural != "" {
+			dest[blockSchema.Plural] = r
+		} else {
+			dest[tpe] = r
+		}
+	}
+
+	return hcl.Diagnostics{}
+}
+
+func validateBlockCount(tpe string, blockSchema Block, blocks hcl.Blocks, missingItemRange hcl.Range) hcl.Diagnostics {
+	minItems := blockSchema.MinItems
+	if blockSchema.Required && minItems < 1 {
+		minItems = 1
+	}
+
+	if len(blocks) < minItems {
+		if len(blocks) == 0 {
 			return hcl.Diagnostics{
 				&hcl.Diagnostic{
-					Severity:    hcl.DiagError,
-					Summary:     fmt.Sprintf("Too many %s blocks found. Only one of them is allowed as per `singleton` set to true", tpe),
-					Detail:      "",
-					Subject:     nil,
-					Context:     nil,
-					Expression:  nil,
-					EvalContext: nil,
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Missing %s block", tpe),
+					Detail:   fmt.Sprintf("A %s block is required.", tpe),
+					Subject:  missingItemRange.Ptr(),
 				},
 			}
 		}
 
-		if blockSchema.Plural != "" {
-			dest[blockSchema.Plural] = r
-		} else {
-			dest[tpe] = r
+		return hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Insufficient %s blocks", tpe),
+				Detail:   fmt.Sprintf("At least %d %q blocks are required.", minItems, tpe),
+				Subject:  missingItemRange.Ptr(),
+			},
 		}
 	}
 
-	return hcl.Diagnostics{}
+	if blockSchema.Singleton && len(blocks) > 1 {
+		return hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     fmt.Sprintf("Too many %s blocks found. Only one of them is allowed as per `singleton` set to true", tpe),
+				Detail:      "",
+				Subject:     blocks[1].DefRange.Ptr(),
+				Context:     nil,
+				Expression:  nil,
+				EvalContext: nil,
+			},
+		}
+	}
+
+	if blockSchema.MaxItems > 0 && len(blocks) > blockSchema.MaxItems {
+		return hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Too many %s blocks", tpe),
+				Detail:   fmt.Sprintf("No more than %d %q blocks are allowed.", blockSchema.MaxItems, tpe),
+				Subject:  blocks[blockSchema.MaxItems].DefRange.Ptr(),
+			},
+		}
+	}
+
+	return nil
 }
 
 func parseAttributesIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent, attrSchemas map[string]Attribute, dest map[string]interface{}) hcl.Diagnostics {
@@ -584,7 +977,9 @@ func parseAttributesIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent,
 
 	var diags hcl.Diagnostics
 
-	for k, attrSchema := range attrSchemas {
+	for _, k := range sortedAttributeNames(attrSchemas) {
+		attrSchema := attrSchemas[k]
+
 		v, ok := remainingAttrs[k]
 
 		if ok {
@@ -639,8 +1034,19 @@ func parseAttributesIntoMap(ctx *hcl.EvalContext, bodyContent *hcl.BodyContent,
 		})
 	}
 
-	for k, v := range remainingAttrs {
-		summary := fmt.Sprintf("attr %q (of %v) is redundant", k, v)
+	redundantAttrs := make([]*hcl.Attribute, 0, len(remainingAttrs))
+
+	for _, v := range remainingAttrs {
+		redundantAttrs = append(redundantAttrs, v)
+	}
+
+	// The first redundant attribute in the source is reported, so that the error is always the same.
+	sort.Slice(redundantAttrs, func(i, j int) bool {
+		return redundantAttrs[i].Range.Start.Byte < redundantAttrs[j].Range.Start.Byte
+	})
+
+	for _, v := range redundantAttrs {
+		summary := fmt.Sprintf("attr %q (of %v) is redundant", v.Name, v)
 
 		return hcl.Diagnostics{
 			&hcl.Diagnostic{
@@ -0,0 +1,321 @@
+package hcl2yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+)
+
+// Parser is the main interface for parsing YAML files, modeled after hclparse.Parser.
+//
+// As well as parsing files, a parser also retains a registry of all of the files it has parsed
+// so that multiple attempts to parse the same file will return the same object, and so the collected files
+// can be used when printing diagnostics.
+//
+// Any diagnostics for parsing a file are only returned once on the first call to parse that file.
+type Parser struct {
+	files map[string]*hcl.File
+
+	// multiFiles are the files of the documents in the streams parsed by ParseYAMLMulti.
+	multiFiles map[string][]*hcl.File
+
+	strict      bool
+	tags        map[string]TagParser
+	pluralizer  Pluralizer
+	labelStyle  LabelStyle
+	debugWriter io.Writer
+
+	navigationSchema *hcl.BodySchema
+}
+
+// ParserOption customizes how a Parser interprets YAML files.
+type ParserOption func(*Parser)
+
+// Strict sets whether scalars with unsupported tags result in errors, which is the default.
+// When disabled, such scalars are treated as if they were strings.
+func Strict(strict bool) ParserOption {
+	return func(p *Parser) {
+		p.strict = strict
+	}
+}
+
+// WithTag registers the TagParser for scalars tagged with the tag, like `!mytag`.
+// It can also be used to override how YAML core schema tags like `!!timestamp` are interpreted.
+//
+// `!!str` and `!!exp` cannot be overridden, as they are what make YAML strings HCL templates and expressions.
+func WithTag(tag string, parser TagParser) ParserOption {
+	return func(p *Parser) {
+		p.tags[tag] = parser
+	}
+}
+
+// WithPluralizer sets the Pluralizer used to find blocks defined under the plural forms of their types,
+// like `services` for `service` blocks. It defaults to EnglishPluralizer(nil).
+// A nil Pluralizer disables plural forms altogether.
+func WithPluralizer(pluralizer Pluralizer) ParserOption {
+	return func(p *Parser) {
+		p.pluralizer = pluralizer
+	}
+}
+
+// WithLabelStyle sets how labels of blocks are written in YAML. It defaults to LabelStyleAuto.
+// MapSchema can override it per block type with Block.LabelStyle.
+func WithLabelStyle(style LabelStyle) ParserOption {
+	return func(p *Parser) {
+		p.labelStyle = style
+	}
+}
+
+// WithNavigationSchema sets the schema of the top-level blocks, so that the diagnostic writer prints the headers of
+// the blocks containing diagnostics, like `in foo "bar":`, as for native HCL. Use gohcl.ImpliedBodySchema to get
+// the schema of a struct.
+//
+// Without the schema, the YAML source alone doesn't tell which keys are blocks, so the path of YAML keys like
+// `in foo[0]:` is printed instead, as for HCL's JSON syntax. The blocks are found once when a file is parsed,
+// so that decoding never changes the navigation.
+func WithNavigationSchema(schema *hcl.BodySchema) ParserOption {
+	return func(p *Parser) {
+		p.navigationSchema = schema
+	}
+}
+
+// WithDebugWriter makes the parser write the yaml.Node tree of every parsed file to w as JSON, for debugging purposes.
+func WithDebugWriter(w io.Writer) ParserOption {
+	return func(p *Parser) {
+		p.debugWriter = w
+	}
+}
+
+// NewParser creates a new parser, ready to parse YAML files.
+func NewParser(opts ...ParserOption) *Parser {
+	p := &Parser{
+		files:      map[string]*hcl.File{},
+		multiFiles: map[string][]*hcl.File{},
+		strict:     true,
+		tags:       map[string]TagParser{},
+		pluralizer: EnglishPluralizer(nil),
+	}
+
+	for tag, parser := range scalarParsers {
+		p.tags[tag] = parser
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
+
+// ParseYAML parses the given buffer (which is assumed to have been loaded from the given filename)
+// and returns the hcl.File object representing it.
+func (p *Parser) ParseYAML(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	if existing := p.files[filename]; existing != nil {
+		return existing, nil
+	}
+
+	var value yaml.Node
+
+	yamlDecoder := yaml.NewDecoder(bytes.NewReader(src))
+
+	if err := yamlDecoder.Decode(&value); err != nil {
+		return nil, hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     err.Error(),
+				Detail:      "",
+				Subject:     nil,
+				Context:     nil,
+				Expression:  nil,
+				EvalContext: nil,
+			},
+		}
+	}
+
+	if diags := p.debug(&value); diags.HasErrors() {
+		return nil, diags
+	}
+
+	s := p.newSource(filename, src)
+
+	if diags := checkAliasExpansion(s, &value); diags.HasErrors() {
+		return nil, diags
+	}
+
+	file := newFile(s, &value)
+
+	p.files[filename] = file
+
+	return file, nil
+}
+
+// ParseYAMLMulti parses a stream of YAML documents separated by `---`, and returns one hcl.File per document.
+//
+// All the returned files share the whole stream as their Bytes, and the ranges within each document are relative to
+// the beginning of the stream. That way a diagnostic writer given the file for any of the documents points at
+// the right lines of the stream. The file of the first document is registered to Files for that purpose.
+//
+// Use hcl.MergeFiles to decode all the documents as a single body.
+func (p *Parser) ParseYAMLMulti(src []byte, filename string) ([]*hcl.File, hcl.Diagnostics) {
+	if existing, ok := p.multiFiles[filename]; ok {
+		return existing, nil
+	}
+
+	s := p.newSource(filename, src)
+
+	yamlDecoder := yaml.NewDecoder(bytes.NewReader(src))
+
+	var files []*hcl.File
+
+	for i := 0; ; i++ {
+		var value yaml.Node
+
+		if err := yamlDecoder.Decode(&value); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return files, hcl.Diagnostics{
+				&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  err.Error(),
+					Detail:   fmt.Sprintf("Failed to parse the YAML document at index %d of %s.", i, filename),
+				},
+			}
+		}
+
+		if diags := p.debug(&value); diags.HasErrors() {
+			return files, diags
+		}
+
+		if diags := checkAliasExpansion(s, &value); diags.HasErrors() {
+			return files, diags
+		}
+
+		files = append(files, newFile(s, &value))
+	}
+
+	p.multiFiles[filename] = files
+
+	if _, ok := p.files[filename]; !ok && len(files) > 0 {
+		p.files[filename] = files[0]
+	}
+
+	return files, nil
+}
+
+// ParseYAMLFile reads the given filename and parses it as YAML, similarly to ParseYAML.
+// An error diagnostic is returned if the given file cannot be read.
+func (p *Parser) ParseYAMLFile(filename string) (*hcl.File, hcl.Diagnostics) {
+	if existing := p.files[filename]; existing != nil {
+		return existing, nil
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The configuration file %q could not be read.", filename),
+			},
+		}
+	}
+
+	return p.ParseYAML(src, filename)
+}
+
+// AddFile allows a caller to record in a parser a file that was parsed some other way,
+// thus allowing it to be included in the registry of sources.
+func (p *Parser) AddFile(filename string, file *hcl.File) {
+	p.files[filename] = file
+}
+
+// Sources returns a map from filenames to the raw source code that was read from them.
+//
+// The arrays underlying the returned slices should not be modified.
+func (p *Parser) Sources() map[string][]byte {
+	ret := make(map[string][]byte)
+
+	for fn, f := range p.files {
+		ret[fn] = f.Bytes
+	}
+
+	return ret
+}
+
+// Files returns a map from filenames to the File objects produced from them.
+// This is intended to be used, for example, to print diagnostics with contextual information
+// via hcl.NewDiagnosticTextWriter.
+//
+// The returned map and all of the objects it refers to directly or indirectly must not be modified.
+func (p *Parser) Files() map[string]*hcl.File {
+	return p.files
+}
+
+func (p *Parser) newSource(filename string, src []byte) *source {
+	s := newSource(filename, src)
+
+	s.strict = p.strict
+	s.tags = p.tags
+	s.pluralizer = p.pluralizer
+	s.labelStyle = p.labelStyle
+	s.navigationSchema = p.navigationSchema
+
+	return s
+}
+
+func (p *Parser) debug(value *yaml.Node) hcl.Diagnostics {
+	if p.debugWriter == nil {
+		return nil
+	}
+
+	debugEncoder := json.NewEncoder(p.debugWriter)
+	debugEncoder.SetIndent("", "  ")
+
+	if err := debugEncoder.Encode(debugNode(value)); err != nil {
+		return hcl.Diagnostics{
+			&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  err.Error(),
+			},
+		}
+	}
+
+	return nil
+}
+
+// debugYamlNode is the JSON representation of a yaml.Node written by WithDebugWriter.
+// Aliases are represented by the anchor names, as the yaml.Node tree can be cyclic via aliases.
+type debugYamlNode struct {
+	Kind    yaml.Kind
+	Style   yaml.Style
+	Tag     string
+	Value   string
+	Anchor  string
+	Line    int
+	Column  int
+	Content []debugYamlNode `json:",omitempty"`
+}
+
+func debugNode(n *yaml.Node) debugYamlNode {
+	d := debugYamlNode{
+		Kind:   n.Kind,
+		Style:  n.Style,
+		Tag:    n.Tag,
+		Value:  n.Value,
+		Anchor: n.Anchor,
+		Line:   n.Line,
+		Column: n.Column,
+	}
+
+	for _, c := range n.Content {
+		d.Content = append(d.Content, debugNode(c))
+	}
+
+	return d
+}
@@ -0,0 +1,71 @@
+package hcl2yaml
+
+import (
+	"strings"
+)
+
+// Pluralizer returns the plural form of the block type.
+//
+// A block of the type `service` can be written either as a single YAML mapping under the key `service`,
+// or as a YAML sequence of mappings under the key returned by the Pluralizer, like `services`.
+// An empty string disables the plural form for the block type.
+type Pluralizer func(singular string) string
+
+var irregularPlurals = map[string]string{
+	"child":  "children",
+	"man":    "men",
+	"person": "people",
+	"woman":  "women",
+}
+
+// EnglishPluralizer returns the Pluralizer that follows the basic English rules.
+// The plurals map can be used to add irregular plurals, or to override the built-in ones.
+func EnglishPluralizer(plurals map[string]string) Pluralizer {
+	return func(singular string) string {
+		if p, ok := plurals[singular]; ok {
+			return p
+		}
+
+		if p, ok := irregularPlurals[singular]; ok {
+			return p
+		}
+
+		return pluralizeEnglish(singular)
+	}
+}
+
+func pluralizeEnglish(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	for _, suffix := range []string{"s", "x", "z", "ch", "sh"} {
+		if strings.HasSuffix(s, suffix) {
+			return s + "es"
+		}
+	}
+
+	if len(s) > 1 && s[len(s)-1] == 'y' && !strings.ContainsRune("aeiou", rune(s[len(s)-2])) {
+		return s[:len(s)-1] + "ies"
+	}
+
+	return s + "s"
+}
+
+// plural returns the plural form of the block type, or an empty string if there's none.
+// Explicit plurals given by the MapSchema takes precedence over the Pluralizer of the source.
+func plural(src *source, tpe string, mapSchemaBlocks map[string]Block) string {
+	if b, ok := mapSchemaBlocks[tpe]; ok && b.Plural != "" {
+		return b.Plural
+	}
+
+	if src.pluralizer == nil {
+		return ""
+	}
+
+	if p := src.pluralizer(tpe); p != tpe {
+		return p
+	}
+
+	return ""
+}
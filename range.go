@@ -0,0 +1,341 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"unicode/utf8"
+)
+
+// source is the YAML source a body or an expression has been parsed from.
+//
+// It translates the line and column numbers yaml.v3 records in each yaml.Node
+// into hcl.Pos values, including byte offsets, so that diagnostics can point at
+// the exact portion of the original file.
+type source struct {
+	fileName string
+	bytes    []byte
+
+	// lineStarts contains the byte offset at which each line begins, starting from line 1.
+	lineStarts []int
+
+	// strict is whether scalars with unsupported tags are errors rather than strings.
+	strict bool
+
+	// tags are the parsers for scalars with tags other than !!str and !!exp.
+	tags map[string]TagParser
+
+	// pluralizer returns the plural forms of block types, or nil if plural forms are disabled.
+	pluralizer Pluralizer
+
+	// labelStyle is how labels of blocks are written, unless the schema specifies one.
+	labelStyle LabelStyle
+
+	// navigationSchema is the schema of the top-level blocks the navigation finds, if any.
+	navigationSchema *hcl.BodySchema
+
+	// scalars are the expressions parsed from the scalar values of the source.
+	scalars scalarExprs
+
+	// schemas are the schemas compiled so far for decoding the bodies of the source.
+	schemas *schemaCache
+
+	nav *navigation
+}
+
+func newSource(fileName string, src []byte) *source {
+	lineStarts := []int{0}
+
+	for i, b := range src {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	s := &source{
+		fileName:   fileName,
+		bytes:      src,
+		lineStarts: lineStarts,
+		strict:     true,
+		tags:       scalarParsers,
+		pluralizer: EnglishPluralizer(nil),
+		scalars:    scalarExprs{},
+		schemas:    &schemaCache{},
+	}
+
+	s.nav = newNavigation(s)
+
+	return s
+}
+
+// offset returns the byte offset of the 1-based line and column yaml.v3 reports for a node.
+// yaml.v3 counts columns in characters, not bytes.
+func (s *source) offset(line, column int) int {
+	if line < 1 {
+		return 0
+	}
+
+	if line > len(s.lineStarts) {
+		return len(s.bytes)
+	}
+
+	off := s.lineStarts[line-1]
+
+	for c := 1; c < column && off < len(s.bytes) && s.bytes[off] != '\n'; c++ {
+		_, size := utf8.DecodeRune(s.bytes[off:])
+		off += size
+	}
+
+	return off
+}
+
+// pos returns the hcl.Pos for the byte offset within the source.
+func (s *source) pos(off int) hcl.Pos {
+	if off > len(s.bytes) {
+		off = len(s.bytes)
+	}
+
+	line := sort.Search(len(s.lineStarts), func(i int) bool {
+		return s.lineStarts[i] > off
+	})
+
+	lineStart := s.lineStarts[line-1]
+
+	return hcl.Pos{
+		Line:   line,
+		Column: utf8.RuneCount(s.bytes[lineStart:off]) + 1,
+		Byte:   off,
+	}
+}
+
+// rangeBetween returns the hcl.Range between the two byte offsets.
+func (s *source) rangeBetween(start, end int) hcl.Range {
+	return hcl.Range{
+		Filename: s.fileName,
+		Start:    s.pos(start),
+		End:      s.pos(end),
+	}
+}
+
+// nodeRange returns the range spanned by the node in the source, including
+// its tag and anchor if any, and all the nested nodes for mappings and sequences.
+func (s *source) nodeRange(n *yaml.Node) hcl.Range {
+	start := s.nodeStart(n)
+
+	return s.rangeBetween(start, s.nodeEnd(n))
+}
+
+// nodeStartRange returns the zero-length range at the beginning of the node.
+func (s *source) nodeStartRange(n *yaml.Node) hcl.Range {
+	start := s.nodeStart(n)
+
+	return s.rangeBetween(start, start)
+}
+
+// firstKeyRange returns the range of the first key of the mapping, or the range of the node if it has no keys.
+func (s *source) firstKeyRange(n *yaml.Node) hcl.Range {
+	if n.Kind == yaml.MappingNode && len(n.Content) > 0 {
+		return s.nodeRange(n.Content[0])
+	}
+
+	return s.nodeRange(n)
+}
+
+func (s *source) nodeStart(n *yaml.Node) int {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		return s.nodeStart(n.Content[0])
+	}
+
+	return s.offset(n.Line, n.Column)
+}
+
+func (s *source) nodeEnd(n *yaml.Node) int {
+	start := s.nodeStart(n)
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return start
+		}
+
+		return s.nodeEnd(n.Content[len(n.Content)-1])
+	case yaml.MappingNode, yaml.SequenceNode:
+		if n.Style&yaml.FlowStyle != 0 {
+			closing := byte('}')
+			if n.Kind == yaml.SequenceNode {
+				closing = ']'
+			}
+
+			from := s.skipProperties(start) + 1
+			if len(n.Content) > 0 {
+				from = s.nodeEnd(n.Content[len(n.Content)-1])
+			}
+
+			for i := from; i < len(s.bytes); i++ {
+				if s.bytes[i] == closing {
+					return i + 1
+				}
+			}
+
+			return len(s.bytes)
+		}
+
+		if len(n.Content) == 0 {
+			return start
+		}
+
+		return s.nodeEnd(n.Content[len(n.Content)-1])
+	case yaml.AliasNode:
+		return start + 1 + len(n.Value)
+	case yaml.ScalarNode:
+		return s.scalarEnd(n, s.skipProperties(start))
+	}
+
+	return start
+}
+
+// skipProperties returns the offset at which the content of the node starting at off begins,
+// skipping its tag and anchor.
+func (s *source) skipProperties(off int) int {
+	for off < len(s.bytes) && (s.bytes[off] == '!' || s.bytes[off] == '&') {
+		for off < len(s.bytes) && !isYamlSpace(s.bytes[off]) {
+			off++
+		}
+
+		for off < len(s.bytes) && isYamlSpace(s.bytes[off]) {
+			off++
+		}
+	}
+
+	return off
+}
+
+// scalarEnd returns the offset right after the scalar whose content begins at off.
+func (s *source) scalarEnd(n *yaml.Node, off int) int {
+	src := s.bytes
+
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		for i := off + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+
+		return len(src)
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		for i := off + 1; i < len(src); i++ {
+			if src[i] == '\'' {
+				if i+1 < len(src) && src[i+1] == '\'' {
+					i++
+					continue
+				}
+
+				return i + 1
+			}
+		}
+
+		return len(src)
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		end := s.lineEnd(off)
+
+		if n.Value == "" {
+			return end
+		}
+
+		indent := -1
+
+		for lineStart := end + 1; lineStart < len(src); {
+			lineEnd := s.lineEnd(lineStart)
+			content := lineStart
+
+			for content < lineEnd && src[content] == ' ' {
+				content++
+			}
+
+			if content < lineEnd {
+				if indent < 0 {
+					indent = content - lineStart
+				} else if content-lineStart < indent {
+					break
+				}
+
+				end = lineEnd
+			}
+
+			lineStart = lineEnd + 1
+		}
+
+		return end
+	}
+
+	// Plain scalars may span multiple lines, in which case yaml.v3 folds line breaks and
+	// indentation into single spaces. Walk the value and the source side-by-side to find the end.
+	v := n.Value
+	i, j := off, 0
+
+	for j < len(v) {
+		if isYamlSpace(v[j]) {
+			for j < len(v) && isYamlSpace(v[j]) {
+				j++
+			}
+
+			for i < len(src) && isYamlSpace(src[i]) {
+				i++
+			}
+
+			continue
+		}
+
+		if i >= len(src) || src[i] != v[j] {
+			break
+		}
+
+		i++
+		j++
+	}
+
+	return i
+}
+
+// scalarContentStart returns the offset at which the content of the scalar begins,
+// skipping its tag, anchor and opening quote, and the header line and indentation of block scalars.
+func (s *source) scalarContentStart(n *yaml.Node) int {
+	off := s.skipProperties(s.nodeStart(n))
+
+	switch {
+	case n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		return off + 1
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		for lineStart := s.lineEnd(off) + 1; lineStart < len(s.bytes); lineStart = s.lineEnd(lineStart) + 1 {
+			content := lineStart
+
+			for content < len(s.bytes) && s.bytes[content] == ' ' {
+				content++
+			}
+
+			if content < len(s.bytes) && !isYamlSpace(s.bytes[content]) {
+				return content
+			}
+		}
+
+		return len(s.bytes)
+	}
+
+	return off
+}
+
+func (s *source) lineEnd(off int) int {
+	for off < len(s.bytes) && s.bytes[off] != '\n' {
+		off++
+	}
+
+	return off
+}
+
+func isYamlSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
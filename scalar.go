@@ -0,0 +1,101 @@
+package hcl2yaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/zclconf/go-cty/cty"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// TagParser converts the value of a YAML scalar of a specific tag into a cty.Value.
+type TagParser func(v string) (cty.Value, error)
+
+// scalarParsers contains parsers for YAML core schema tags, that evaluate to static values.
+//
+// !!str and !!exp are not contained here, as they are parsed into HCL templates and expressions respectively.
+var scalarParsers = map[string]TagParser{
+	"!!null":      parseYamlNull,
+	"!!bool":      parseYamlBool,
+	"!!int":       parseYamlInt,
+	"!!float":     parseYamlFloat,
+	"!!timestamp": parseYamlTimestamp,
+	"!!binary":    parseYamlBinary,
+}
+
+func parseYamlNull(v string) (cty.Value, error) {
+	return cty.NullVal(cty.DynamicPseudoType), nil
+}
+
+func parseYamlBool(v string) (cty.Value, error) {
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "y":
+		return cty.True, nil
+	case "false", "no", "off", "n":
+		return cty.False, nil
+	}
+
+	return cty.NilVal, fmt.Errorf("invalid boolean %q", v)
+}
+
+// parseYamlInt parses decimal, hexadecimal(0x), octal(0o or a leading 0) and binary(0b) integers,
+// optionally containing underscores between digits, in arbitrary precision.
+func parseYamlInt(v string) (cty.Value, error) {
+	i, ok := new(big.Int).SetString(v, 0)
+	if !ok {
+		return cty.NilVal, fmt.Errorf("invalid integer %q", v)
+	}
+
+	return cty.NumberVal(new(big.Float).SetInt(i)), nil
+}
+
+func parseYamlFloat(v string) (cty.Value, error) {
+	switch strings.ToLower(v) {
+	case ".inf", "+.inf":
+		return cty.PositiveInfinity, nil
+	case "-.inf":
+		return cty.NegativeInfinity, nil
+	case ".nan":
+		return cty.NilVal, fmt.Errorf("NaN is not supported, as HCL numbers cannot represent it")
+	}
+
+	val, err := cty.ParseNumberVal(strings.Replace(v, "_", "", -1))
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("invalid float %q", v)
+	}
+
+	return val, nil
+}
+
+// timestampFormats are the timestamp formats yaml.v3 accepts.
+var timestampFormats = []string{
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+// parseYamlTimestamp parses a timestamp into a RFC 3339 string, which is the format HCL functions like
+// `formatdate` and `timeadd` expect.
+func parseYamlTimestamp(v string) (cty.Value, error) {
+	for _, format := range timestampFormats {
+		if t, err := time.Parse(format, v); err == nil {
+			return cty.StringVal(t.Format(time.RFC3339Nano)), nil
+		}
+	}
+
+	return cty.NilVal, fmt.Errorf("invalid timestamp %q", v)
+}
+
+// parseYamlBinary validates base64-encoded binary data and returns it as a base64 string without
+// line breaks, as HCL has no binary type and HCL functions like `base64decode` consume base64 strings.
+func parseYamlBinary(v string) (cty.Value, error) {
+	encoded := strings.Join(strings.Fields(v), "")
+
+	if _, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+		return cty.NilVal, fmt.Errorf("invalid base64-encoded binary: %v", err)
+	}
+
+	return cty.StringVal(encoded), nil
+}
@@ -0,0 +1,40 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// scalarExprs are the expressions of the scalar values in the source, parsed once when the source is parsed,
+// so that decoding a body many times, like gohcl does for each block, doesn't parse the same templates again.
+//
+// It is never modified once the source is parsed, so it is read without locks by concurrent decodes.
+// Only expressions parsed without diagnostics are kept, so that diagnostics are never shared between callers,
+// which may append to them.
+type scalarExprs map[*yaml.Node]hcl.Expression
+
+// addDocument parses the scalar values in the YAML document, that is all the scalars but the keys of mappings.
+// It is called only while the source is parsed.
+func (s scalarExprs) addDocument(src *source, doc *yaml.Node) {
+	f := &yamlBody{src: src}
+
+	var add func(n *yaml.Node)
+	add = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, c := range n.Content {
+				add(c)
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				add(n.Content[i])
+			}
+		case yaml.ScalarNode:
+			if expr, diags := f.parseScalar(n); len(diags) == 0 {
+				s[n] = expr
+			}
+		}
+	}
+
+	add(doc)
+}
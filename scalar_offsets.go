@@ -0,0 +1,191 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+	"reflect"
+)
+
+// scalarOffsets maps the byte offsets within the value of a scalar to the byte offsets in the YAML source.
+//
+// Expressions and templates are parsed from the values of scalars, as yaml.v3 strips the indentation of
+// block scalars, folds line breaks, and unescapes quoted strings. So the positions the parser computes
+// from the value drift from the source on every line after the first, which remap corrects.
+type scalarOffsets struct {
+	src *source
+
+	// start is the position at which the value is parsed, that is the beginning of the scalar content.
+	start hcl.Pos
+
+	// offsets are the source offsets of the bytes of the value, followed by the offset of the end of the value.
+	// It is nil when the value is identical to the source, in which case the parsed positions need no correction.
+	offsets []int
+}
+
+// scalarOffsets maps the value of the scalar to the source.
+//
+// The value and the source are walked side-by-side, matching every whitespace run of the value, like
+// a line break followed by the indentation of a block scalar, with the whitespace run at the same place in the source.
+// The rest of the value after anything else differs, like an escape sequence, is mapped as if it were identical to
+// the source.
+func (s *source) scalarOffsets(n *yaml.Node) *scalarOffsets {
+	start := s.scalarContentStart(n)
+
+	o := &scalarOffsets{src: s, start: s.pos(start)}
+
+	v, src := n.Value, s.bytes
+
+	if start+len(v) <= len(src) && string(src[start:start+len(v)]) == v {
+		return o
+	}
+
+	o.offsets = make([]int, len(v)+1)
+
+	i, j := start, 0
+
+	for j < len(v) {
+		if isYamlSpace(v[j]) {
+			valueRun, sourceRun := j, i
+
+			for j < len(v) && isYamlSpace(v[j]) {
+				j++
+			}
+
+			for i < len(src) && isYamlSpace(src[i]) {
+				i++
+			}
+
+			// The first whitespace, usually a line break, is mapped to the beginning of the run and the rest to its end,
+			// so that the indentation kept in the value, like the one of lines within a heredoc, points at the source.
+			for k := valueRun; k < j; k++ {
+				off := i - (j - k)
+				if k == valueRun || off < sourceRun {
+					off = sourceRun
+				}
+
+				o.offsets[k] = off
+			}
+
+			continue
+		}
+
+		if i >= len(src) || src[i] != v[j] {
+			break
+		}
+
+		o.offsets[j] = i
+
+		i++
+		j++
+	}
+
+	for ; j < len(v); j++ {
+		o.offsets[j] = i
+
+		if i < len(src) {
+			i++
+		}
+	}
+
+	o.offsets[len(v)] = start
+
+	if len(v) > 0 {
+		o.offsets[len(v)] = o.offsets[len(v)-1] + 1
+	}
+
+	return o
+}
+
+// pos returns the source position of the position computed by the parser from the value.
+func (o *scalarOffsets) pos(p hcl.Pos) hcl.Pos {
+	k := p.Byte - o.start.Byte
+
+	if k < 0 {
+		k = 0
+	}
+
+	if k >= len(o.offsets) {
+		k = len(o.offsets) - 1
+	}
+
+	return o.src.pos(o.offsets[k])
+}
+
+var rangeType = reflect.TypeOf(hcl.Range{})
+
+// visitedValue identifies a value by its address and type, as a struct and its first field share the address.
+type visitedValue struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// remap corrects all the ranges within the values parsed from the scalar value, like expressions, traversals and
+// diagnostics, to point at the source. Each range is corrected once, even when it is reachable from many values.
+func (o *scalarOffsets) remap(values ...interface{}) {
+	if o.offsets == nil {
+		return
+	}
+
+	visited := map[visitedValue]struct{}{}
+
+	for _, v := range values {
+		o.remapValue(reflect.ValueOf(v), visited)
+	}
+}
+
+func (o *scalarOffsets) remapValue(v reflect.Value, visited map[visitedValue]struct{}) {
+	if v.CanAddr() {
+		k := visitedValue{addr: v.UnsafeAddr(), typ: v.Type()}
+
+		if _, ok := visited[k]; ok {
+			return
+		}
+
+		visited[k] = struct{}{}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			o.remapValue(v.Elem(), visited)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+
+		e := v.Elem()
+
+		// Structs within interfaces, like the steps of traversals, are copied so that they can be modified.
+		if e.Kind() == reflect.Struct && v.CanSet() {
+			c := reflect.New(e.Type()).Elem()
+			c.Set(e)
+			o.remapValue(c, visited)
+			v.Set(c)
+
+			return
+		}
+
+		o.remapValue(e, visited)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			o.remapValue(v.Index(i), visited)
+		}
+	case reflect.Struct:
+		if v.Type() == rangeType {
+			if v.CanSet() {
+				r := v.Interface().(hcl.Range)
+				r.Start, r.End = o.pos(r.Start), o.pos(r.End)
+				v.Set(reflect.ValueOf(r))
+			}
+
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				o.remapValue(v.Field(i), visited)
+			}
+		}
+	}
+}
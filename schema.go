@@ -0,0 +1,145 @@
+package hcl2yaml
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// compiledSchema is the lookup tables for a hcl.BodySchema. It is never modified once compiled.
+type compiledSchema struct {
+	attrs  map[string]hcl.AttributeSchema
+	blocks map[string]hcl.BlockHeaderSchema
+
+	// blockTypes maps the keys of blocks, including the plural forms, to the block types.
+	blockTypes map[string]string
+
+	// labelStyles are the label styles of block types given by the MapSchema when decoded via DecodeBodyIntoMap.
+	labelStyles map[string]LabelStyle
+}
+
+// schemaCache memoizes the compiled schemas of a source, keyed by the contents of the schemas rather than
+// their pointers, so that it stays sound when a schema is modified between calls, and is shared by the schemas
+// gohcl builds anew for each block.
+type schemaCache struct {
+	mu      sync.RWMutex
+	schemas map[string]*compiledSchema
+}
+
+// get returns the compiled schema, compiling it on the first call for the contents of the schema.
+func (c *schemaCache) get(src *source, schema *hcl.BodySchema, mapSchemaBlocks map[string]Block) *compiledSchema {
+	var buf [512]byte
+
+	key := appendSchemaKey(buf[:0], schema, mapSchemaBlocks)
+
+	c.mu.RLock()
+	s, ok := c.schemas[string(key)]
+	c.mu.RUnlock()
+
+	if ok {
+		return s
+	}
+
+	s = compileSchema(src, schema, mapSchemaBlocks)
+
+	c.mu.Lock()
+	if c.schemas == nil {
+		c.schemas = map[string]*compiledSchema{}
+	}
+	c.schemas[string(key)] = s
+	c.mu.Unlock()
+
+	return s
+}
+
+// appendSchemaKey appends everything compileSchema reads from the schema to the key.
+func appendSchemaKey(key []byte, schema *hcl.BodySchema, mapSchemaBlocks map[string]Block) []byte {
+	for _, a := range schema.Attributes {
+		key = append(key, 'a')
+		key = strconv.AppendQuote(key, a.Name)
+		key = strconv.AppendBool(key, a.Required)
+	}
+
+	for _, b := range schema.Blocks {
+		key = append(key, 'b')
+		key = strconv.AppendQuote(key, b.Type)
+
+		for _, l := range b.LabelNames {
+			key = append(key, 'l')
+			key = strconv.AppendQuote(key, l)
+		}
+
+		if mb, ok := mapSchemaBlocks[b.Type]; ok {
+			key = append(key, 'p')
+			key = strconv.AppendQuote(key, mb.Plural)
+			key = strconv.AppendInt(key, int64(mb.LabelStyle), 10)
+		}
+	}
+
+	return key
+}
+
+// compileSchema builds the lookup tables for the schema.
+func compileSchema(src *source, schema *hcl.BodySchema, mapSchemaBlocks map[string]Block) *compiledSchema {
+	s := &compiledSchema{
+		attrs:      make(map[string]hcl.AttributeSchema, len(schema.Attributes)),
+		blocks:     make(map[string]hcl.BlockHeaderSchema, len(schema.Blocks)),
+		blockTypes: make(map[string]string, len(schema.Blocks)),
+	}
+
+	for _, a := range schema.Attributes {
+		s.attrs[a.Name] = a
+	}
+
+	for _, b := range schema.Blocks {
+		s.blocks[b.Type] = b
+		s.blockTypes[b.Type] = b.Type
+
+		if mb, ok := mapSchemaBlocks[b.Type]; ok && mb.LabelStyle != LabelStyleAuto {
+			if s.labelStyles == nil {
+				s.labelStyles = map[string]LabelStyle{}
+			}
+
+			s.labelStyles[b.Type] = mb.LabelStyle
+		}
+	}
+
+	// Plural forms never shadow the names in the schema, like a `rules` block type along with a `rule` block type,
+	// so that the result doesn't depend on the order of the schema.
+	for _, b := range schema.Blocks {
+		p := plural(src, b.Type, mapSchemaBlocks)
+		if p == "" {
+			continue
+		}
+
+		if _, ok := s.attrs[p]; ok {
+			continue
+		}
+
+		if _, ok := s.blockTypes[p]; ok {
+			continue
+		}
+
+		s.blockTypes[p] = b.Type
+	}
+
+	return s
+}
+
+// names returns the attribute names and the keys of blocks in order, used to suggest names for unsupported keys.
+func (s *compiledSchema) names() []string {
+	var names []string
+
+	for k := range s.attrs {
+		names = append(names, k)
+	}
+
+	for k := range s.blockTypes {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -1,19 +1,315 @@
 package hcl2yaml
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"github.com/hashicorp/hcl/v2"
+	"gopkg.in/yaml.v3"
+)
 
-func mappingKVs(valNode *yaml.Node) map[string]*yaml.Node {
-	c := valNode.Content
+// documentContent returns the root node of the YAML document.
+// An empty document is treated as an empty mapping, so that it can be decoded as an empty body.
+func documentContent(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: doc.Line, Column: doc.Column}
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" && root.Value == "" {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: root.Line, Column: root.Column}
+	}
+
+	return root
+}
+
+// mappingEntry is a key-value pair of a YAML mapping, whose value has been resolved from an alias if any.
+type mappingEntry struct {
+	key   *yaml.Node
+	value *yaml.Node
+
+	// alias is the alias node the value has been resolved from, or nil if the value is not an alias.
+	alias *yaml.Node
+}
+
+// mappingEntries returns the entries of the YAML mapping in order, with aliases resolved and merge keys(<<) expanded.
+//
+// Duplicate keys are errors, like attributes defined more than once are in native HCL.
+// Merge keys follow the YAML 1.1 merge key semantics. That is, keys defined in the mapping
+// override merged ones, and an earlier mapping wins when merging a sequence of mappings.
+//
+// ancestors are the nodes enclosing the mapping, used to detect aliases that refer to their own ancestors.
+func mappingEntries(src *source, node *yaml.Node, ancestors []*yaml.Node) ([]mappingEntry, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	parents := appendNode(ancestors, node)
+
+	explicitKeys := map[string]*yaml.Node{}
+
+	duplicates := map[*yaml.Node]struct{}{}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		k := node.Content[i]
+
+		if isMergeKey(k) || k.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		if original, ok := explicitKeys[k.Value]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate key",
+				Detail:   fmt.Sprintf("The key %q was already defined at %s. Each key may be defined only once in a mapping.", k.Value, src.nodeRange(original)),
+				Subject:  src.nodeRange(k).Ptr(),
+				Context:  hcl.RangeOver(src.nodeRange(original), src.nodeRange(k)).Ptr(),
+			})
+
+			duplicates[k] = struct{}{}
+
+			continue
+		}
+
+		explicitKeys[k.Value] = k
+	}
+
+	mergedKeys := map[string]struct{}{}
+
+	var entries []mappingEntry
 
-	m := map[string]*yaml.Node{}
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
 
-	for i := 0; i < len(c); i += 2 {
-		keyNode := c[i]
-		valNode := c[i+1]
+		if _, dup := duplicates[keyNode]; dup {
+			continue
+		}
+
+		if !isMergeKey(keyNode) {
+			v, d := resolveNode(src, valueNode, parents)
+			diags = append(diags, d...)
+
+			if d.HasErrors() {
+				continue
+			}
+
+			e := mappingEntry{key: keyNode, value: v}
+			if valueNode.Kind == yaml.AliasNode {
+				e.alias = valueNode
+			}
+
+			entries = append(entries, e)
+
+			continue
+		}
+
+		for _, m := range mergeSources(valueNode) {
+			v, d := resolveNode(src, m, parents)
+			diags = append(diags, d...)
+
+			if d.HasErrors() {
+				continue
+			}
+
+			if v.Kind != yaml.MappingNode {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid merge key value",
+					Detail:   fmt.Sprintf("The value of the merge key(<<) must be a mapping or a sequence of mappings, but got a %s.", kindName(v.Kind)),
+					Subject:  src.nodeRange(m).Ptr(),
+					Context:  src.nodeRange(node).Ptr(),
+				})
+
+				continue
+			}
+
+			merged, d := mappingEntries(src, v, parents)
+			diags = append(diags, withAliasContext(src, d, m)...)
+
+			for _, e := range merged {
+				k := e.key.Value
+
+				if _, explicit := explicitKeys[k]; explicit {
+					continue
+				}
+
+				if _, done := mergedKeys[k]; done {
+					continue
+				}
+
+				mergedKeys[k] = struct{}{}
+
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries, diags
+}
+
+// mergeSources returns the nodes to be merged for the value of a merge key.
+func mergeSources(valueNode *yaml.Node) []*yaml.Node {
+	if valueNode.Kind == yaml.SequenceNode {
+		return valueNode.Content
+	}
+
+	return []*yaml.Node{valueNode}
+}
 
-		m[keyNode.Value] = valNode
+// kindName returns the name of the kind of YAML nodes to be shown in diagnostics, like "mapping".
+func kindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.DocumentNode:
+		return "document"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.ScalarNode:
+		return "scalar"
+	case yaml.AliasNode:
+		return "alias"
 	}
 
-	return m
+	return fmt.Sprintf("unknown kind %d", kind)
 }
 
+func isMergeKey(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Value == "<<" && (n.Tag == "" || n.Tag == "!!merge")
+}
+
+// resolveNode returns the node the alias node refers to, or the node itself if it isn't an alias.
+//
+// It returns an error when the alias refers to one of the ancestors, which would result in an infinite recursion.
+func resolveNode(src *source, n *yaml.Node, ancestors []*yaml.Node) (*yaml.Node, hcl.Diagnostics) {
+	if n.Kind != yaml.AliasNode {
+		return n, nil
+	}
+
+	target := n.Alias
+	for target.Kind == yaml.AliasNode {
+		target = target.Alias
+	}
+
+	for _, a := range ancestors {
+		if a == target {
+			return nil, hcl.Diagnostics{
+				&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Cyclic alias",
+					Detail:   fmt.Sprintf("The alias *%s refers to the anchor &%s at %s, which encloses the alias itself.", n.Value, target.Anchor, src.nodeRange(target)),
+					Subject:  src.nodeRange(n).Ptr(),
+					Context:  src.nodeRange(target).Ptr(),
+				},
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// aliasExpansionRatio and aliasExpansionBudget limit how many nodes a document may expand to by resolving its aliases.
+// The limit is aliasExpansionRatio times the number of nodes in the document plus aliasExpansionBudget,
+// so that nested aliases like the "billion laughs" can't exhaust CPU and memory while legit aliases never hit it.
+const (
+	aliasExpansionRatio  = 10
+	aliasExpansionBudget = 100000
+)
+
+// checkAliasExpansion returns an error when resolving all the aliases in the document would expand it beyond
+// the limit, similarly to how yaml.v3 limits aliases when decoding into Go values.
+//
+// The expanded size of each node is computed only once, so that the check itself is linear to the size of the document.
+func checkAliasExpansion(src *source, doc *yaml.Node) hcl.Diagnostics {
+	var nodes int
+
+	var countNodes func(n *yaml.Node)
+	countNodes = func(n *yaml.Node) {
+		nodes++
+
+		for _, c := range n.Content {
+			countNodes(c)
+		}
+	}
+
+	countNodes(doc)
+
+	limit := aliasExpansionRatio*nodes + aliasExpansionBudget
+
+	sizes := map[*yaml.Node]int{}
+
+	var worst *yaml.Node
+
+	var expandedSize func(n *yaml.Node) int
+	expandedSize = func(n *yaml.Node) int {
+		if size, ok := sizes[n]; ok {
+			return size
+		}
+
+		// Cyclic aliases are reported when the mapping enclosing them is decoded.
+		sizes[n] = 1
+
+		size := 1
+
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			size = expandedSize(n.Alias)
+
+			if worst == nil || size > sizes[worst] {
+				worst = n
+			}
+		}
+
+		for _, c := range n.Content {
+			if size += expandedSize(c); size > limit {
+				size = limit + 1
+			}
+		}
+
+		sizes[n] = size
+
+		return size
+	}
+
+	if expandedSize(doc) <= limit {
+		return nil
+	}
+
+	return hcl.Diagnostics{
+		&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Too many alias expansions",
+			Detail:   fmt.Sprintf("Resolving the aliases would expand the document of %d nodes to more than %d nodes. Aliases referring to anchors that contain aliases themselves multiply the size of the document, like the alias *%s does.", nodes, limit, worst.Value),
+			Subject:  src.nodeRange(worst).Ptr(),
+			Context:  src.nodeRange(worst.Alias).Ptr(),
+		},
+	}
+}
+
+// withAliasContext adds the location of the alias to the diagnostics produced for the node it refers to,
+// so that the diagnostics point at both the anchor and the alias.
+//
+// The diagnostics are copied rather than modified, as they can be shared by the parsed tree.
+func withAliasContext(src *source, diags hcl.Diagnostics, alias *yaml.Node) hcl.Diagnostics {
+	if alias == nil || alias.Kind != yaml.AliasNode {
+		return diags
+	}
+
+	withContext := make(hcl.Diagnostics, len(diags))
+
+	for i, d := range diags {
+		c := *d
+		c.Detail += fmt.Sprintf("\n\nThe value is referenced by the alias *%s at %s.", alias.Value, src.nodeRange(alias))
+		withContext[i] = &c
+	}
+
+	return withContext
+}
+
+// sharedDiags returns the diagnostics retained by the parsed tree, capping the capacity of the slice
+// so that callers appending to it never write to the array shared with other callers.
+func sharedDiags(diags hcl.Diagnostics) hcl.Diagnostics {
+	return diags[:len(diags):len(diags)]
+}
+
+// appendNode returns a new slice containing the nodes followed by n, without modifying the underlying array of nodes.
+func appendNode(nodes []*yaml.Node, n *yaml.Node) []*yaml.Node {
+	return append(nodes[:len(nodes):len(nodes)], n)
+}